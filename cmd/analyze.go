@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"bold/pkg/config"
 	"bold/pkg/cost"
 	"bold/pkg/graph"
+	"bold/pkg/ipplan"
 	"bold/pkg/parser"
 	"fmt"
 	"os"
@@ -14,6 +16,8 @@ import (
 func NewAnalyzeCommand() *cobra.Command {
 	var format string
 	var outputFile string
+	var configPath, profile string
+	var parseOpts parser.ParseOptions
 
 	cmd := &cobra.Command{
 		Use:   "analyze [manifest_file]",
@@ -22,17 +26,32 @@ func NewAnalyzeCommand() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			manifestFile := args[0]
 
-			service, err := parser.ParseManifest(manifestFile)
+			cfg, err := config.LoadConfig(configPath, profile)
+			if err != nil {
+				return fmt.Errorf("failed to load configuration: %w", err)
+			}
+			parseOpts.LibraryDir = cfg.Components.Library
+
+			service, err := parser.ParseManifestWithOptions(manifestFile, parseOpts)
 			if err != nil {
 				return fmt.Errorf("failed to parse manifest: %w", err)
 			}
 
+			var overlay string
+			if parseOpts.Env != "" {
+				overlay, err = analyzeOverlay(manifestFile, parseOpts, service)
+				if err != nil {
+					return err
+				}
+			}
+
 			fmt.Println("🔍 Analyzing infrastructure manifest...")
 			fmt.Printf("Service: %s (Owner: %s)\n", service.Metadata.Name, service.Metadata.Owner)
 			fmt.Printf("Providers: %d\n\n", len(service.Providers))
 
 			dependencyGraph := graph.GenerateDependencyGraph(service)
 			costReport := cost.EstimateCosts(service)
+			ipPlan := ipplan.BuildIPPlan(service)
 
 			var output string
 
@@ -45,10 +64,17 @@ func NewAnalyzeCommand() *cobra.Command {
 				output = graph.GenerateDotGraph(dependencyGraph)
 			case "cost":
 				output = cost.FormatCostReport(costReport)
+			case "ipplan":
+				output = ipplan.FormatIPPlan(ipPlan)
+			case "overlay":
+				if parseOpts.Env == "" {
+					return fmt.Errorf("the overlay format requires --env")
+				}
+				output = overlay
 			case "full":
-				output = generateFullAnalysis(dependencyGraph, costReport)
+				output = generateFullAnalysis(dependencyGraph, costReport, ipPlan) + overlay
 			default:
-				output = generateFullAnalysis(dependencyGraph, costReport)
+				output = generateFullAnalysis(dependencyGraph, costReport, ipPlan) + overlay
 			}
 
 			if outputFile != "" {
@@ -65,13 +91,15 @@ func NewAnalyzeCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&format, "format", "f", "full", "Output format (tree, mermaid, dot, cost, full)")
+	cmd.Flags().StringVarP(&format, "format", "f", "full", "Output format (tree, mermaid, dot, cost, ipplan, overlay, full)")
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (optional)")
+	addConfigFlags(cmd, &configPath, &profile)
+	addManifestFlags(cmd, &parseOpts.Env, &parseOpts.Vars, &parseOpts.VarFiles)
 
 	return cmd
 }
 
-func generateFullAnalysis(dependencyGraph *graph.DependencyGraph, costReport *cost.CostReport) string {
+func generateFullAnalysis(dependencyGraph *graph.DependencyGraph, costReport *cost.CostReport, ipPlan *ipplan.Report) string {
 	var analysis strings.Builder
 
 	analysis.WriteString("🚀 BOLT INFRASTRUCTURE ANALYSIS\n")
@@ -85,6 +113,36 @@ func generateFullAnalysis(dependencyGraph *graph.DependencyGraph, costReport *co
 	analysis.WriteString("💰 COST ESTIMATION\n")
 	analysis.WriteString("------------------\n")
 	analysis.WriteString(cost.FormatCostReport(costReport))
+	analysis.WriteString("\n")
+
+	analysis.WriteString(ipplan.FormatIPPlan(ipPlan))
 
 	return analysis.String()
 }
+
+// analyzeOverlay shows the manifest with the overlay of --env applied and how its cost
+// differs from the base manifest.
+func analyzeOverlay(manifestFile string, parseOpts parser.ParseOptions, service *parser.Service) (string, error) {
+	effective, err := parser.EffectiveManifest(manifestFile, parseOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render effective manifest: %w", err)
+	}
+
+	var analysis strings.Builder
+
+	analysis.WriteString(fmt.Sprintf("\n📄 EFFECTIVE MANIFEST (%s)\n", parseOpts.Env))
+	analysis.WriteString("-----------------------------\n")
+	analysis.Write(effective)
+	analysis.WriteString("\n")
+
+	baseOpts := parseOpts
+	baseOpts.Env = ""
+	base, err := parser.ParseManifestWithOptions(manifestFile, baseOpts)
+	if err != nil {
+		analysis.WriteString(fmt.Sprintf("Cost difference unavailable: the base manifest is invalid on its own: %v\n", err))
+		return analysis.String(), nil
+	}
+	analysis.WriteString(cost.FormatCostDiff(cost.EstimateCosts(base), cost.EstimateCosts(service), parseOpts.Env))
+
+	return analysis.String(), nil
+}
@@ -7,12 +7,19 @@ import (
 )
 
 func NewBootstrapCommand() *cobra.Command {
-	return &cobra.Command{
+	var opts workflow.Options
+
+	cmd := &cobra.Command{
 		Use:   "bootstrap [manifest_file]",
 		Short: "Mem-bootstrap sebuah layanan (membuat atau memperbarui infrastruktur)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return workflow.Run(args[0], "apply")
+			return workflow.Run(args[0], "apply", opts)
 		},
 	}
+
+	addWorkflowFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.AutoApprove, "auto-approve", false, "Lewati konfirmasi sebelum apply")
+
+	return cmd
 }
@@ -7,12 +7,19 @@ import (
 )
 
 func NewDestroyCommand() *cobra.Command {
-	return &cobra.Command{
+	var opts workflow.Options
+
+	cmd := &cobra.Command{
 		Use:   "destroy [manifest_file]",
 		Short: "Menghancurkan (destroy) semua sumber daya dari sebuah layanan",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return workflow.Run(args[0], "destroy")
+			return workflow.Run(args[0], "destroy", opts)
 		},
 	}
+
+	addWorkflowFlags(cmd, &opts)
+	cmd.Flags().BoolVar(&opts.AutoApprove, "auto-approve", false, "Lewati konfirmasi sebelum destroy")
+
+	return cmd
 }
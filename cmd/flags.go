@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bold/pkg/workflow"
+
+	"github.com/spf13/cobra"
+)
+
+// addWorkflowFlags mendaftarkan flag yang dipakai bersama oleh plan, bootstrap, dan destroy.
+// --auto-approve hanya didaftarkan oleh bootstrap dan destroy, karena plan tidak meminta konfirmasi.
+func addWorkflowFlags(cmd *cobra.Command, opts *workflow.Options) {
+	cmd.Flags().StringVar(&opts.BuildDir, "build-dir", "", "Direktori build dan state (default ./bolt_build/<service>/<environment>)")
+	addConfigFlags(cmd, &opts.ConfigPath, &opts.Profile)
+	addManifestFlags(cmd, &opts.Env, &opts.Vars, &opts.VarFiles)
+}
+
+// addConfigFlags mendaftarkan flag untuk memilih file konfigurasi dan profilnya.
+func addConfigFlags(cmd *cobra.Command, configPath, profile *string) {
+	cmd.Flags().StringVar(configPath, "config", "", "File konfigurasi (default: ./bolt.yaml, ./config.yaml, lalu $XDG_CONFIG_HOME/bolt/config.yaml)")
+	cmd.Flags().StringVar(profile, "profile", "", "Profil konfigurasi yang dipakai (default $BOLT_PROFILE)")
+}
+
+// addManifestFlags mendaftarkan flag untuk overlay environment dan nilai variabel manifest.
+func addManifestFlags(cmd *cobra.Command, env *string, vars, varFiles *[]string) {
+	cmd.Flags().StringVar(env, "env", "", "Environment yang overlay-nya (overlays/<env>.yaml) diterapkan ke manifest")
+	cmd.Flags().StringArrayVar(vars, "var", nil, "Nilai variabel manifest dalam bentuk name=value (bisa diulang)")
+	cmd.Flags().StringArrayVar(varFiles, "var-file", nil, "File YAML berisi nilai variabel manifest (bisa diulang)")
+}
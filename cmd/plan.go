@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"bold/pkg/workflow"
+
+	"github.com/spf13/cobra"
+)
+
+func NewPlanCommand() *cobra.Command {
+	var opts workflow.Options
+
+	cmd := &cobra.Command{
+		Use:   "plan [manifest_file]",
+		Short: "Menampilkan ringkasan perubahan infrastruktur tanpa menerapkannya",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return workflow.Run(args[0], "plan", opts)
+		},
+	}
+
+	addWorkflowFlags(cmd, &opts)
+
+	return cmd
+}
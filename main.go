@@ -2,6 +2,7 @@ package main
 
 import (
 	"bold/cmd"
+	_ "bold/pkg/provider/builtin"
 	"fmt"
 	"os"
 
@@ -14,6 +15,7 @@ func main() {
 		Short: "Bolt adalah tool untuk merakit infrastruktur dari definisi layanan.",
 		Long:  `Bolt mengambil definisi layanan abstrak dan mensintesisnya menjadi infrastruktur nyata menggunakan engine seperti OpenTofu.`,
 	}
+	rootCmd.AddCommand(cmd.NewPlanCommand())
 	rootCmd.AddCommand(cmd.NewBootstrapCommand())
 	rootCmd.AddCommand(cmd.NewDestroyCommand())
 	rootCmd.AddCommand(cmd.NewAnalyzeCommand())
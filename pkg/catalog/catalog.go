@@ -0,0 +1,169 @@
+// Package catalog lists the instance types Bolt knows for each provider type, with their
+// capacity and on-demand price. The compiler uses it to resolve provider-agnostic size
+// classes and the cost package prices instances from it.
+package catalog
+
+import (
+	"fmt"
+	"sort"
+)
+
+// InstanceType is a compute instance type offered by a cloud.
+type InstanceType struct {
+	Name     string
+	CPU      int
+	MemoryGB float64
+	// HourlyPrice is the on-demand Linux price in USD.
+	HourlyPrice float64
+	// Arch is the CPU architecture, amd64 or arm64, in the form of OSImage.Arch.
+	Arch string
+}
+
+// Requirements is the minimum capacity an instance type must provide.
+type Requirements struct {
+	CPU      int
+	MemoryGB float64
+	// Arch is the architecture the instance type must have; empty selects amd64.
+	Arch string
+}
+
+// sizeClasses maps the provider-agnostic size classes to their minimum capacity.
+var sizeClasses = map[string]Requirements{
+	"small":  {CPU: 1, MemoryGB: 2},
+	"medium": {CPU: 2, MemoryGB: 4},
+	"large":  {CPU: 4, MemoryGB: 16},
+	"xlarge": {CPU: 8, MemoryGB: 32},
+}
+
+// instanceTypes is keyed by provider type. Prices are for us-east-1, eastus and us-central1.
+var instanceTypes = map[string][]InstanceType{
+	"aws": {
+		{Name: "t2.micro", CPU: 1, MemoryGB: 1, HourlyPrice: 0.0116, Arch: "amd64"},
+		{Name: "t3.micro", CPU: 2, MemoryGB: 1, HourlyPrice: 0.0104, Arch: "amd64"},
+		{Name: "t2.small", CPU: 1, MemoryGB: 2, HourlyPrice: 0.023, Arch: "amd64"},
+		{Name: "t3.small", CPU: 2, MemoryGB: 2, HourlyPrice: 0.0208, Arch: "amd64"},
+		{Name: "t3.medium", CPU: 2, MemoryGB: 4, HourlyPrice: 0.0416, Arch: "amd64"},
+		{Name: "t3.large", CPU: 2, MemoryGB: 8, HourlyPrice: 0.0832, Arch: "amd64"},
+		{Name: "t3.xlarge", CPU: 4, MemoryGB: 16, HourlyPrice: 0.1664, Arch: "amd64"},
+		{Name: "t3.2xlarge", CPU: 8, MemoryGB: 32, HourlyPrice: 0.3328, Arch: "amd64"},
+		{Name: "c5.large", CPU: 2, MemoryGB: 4, HourlyPrice: 0.085, Arch: "amd64"},
+		{Name: "m5.large", CPU: 2, MemoryGB: 8, HourlyPrice: 0.096, Arch: "amd64"},
+		{Name: "m5.xlarge", CPU: 4, MemoryGB: 16, HourlyPrice: 0.192, Arch: "amd64"},
+		{Name: "m5.2xlarge", CPU: 8, MemoryGB: 32, HourlyPrice: 0.384, Arch: "amd64"},
+		{Name: "t4g.micro", CPU: 2, MemoryGB: 1, HourlyPrice: 0.0084, Arch: "arm64"},
+		{Name: "t4g.small", CPU: 2, MemoryGB: 2, HourlyPrice: 0.0168, Arch: "arm64"},
+		{Name: "t4g.medium", CPU: 2, MemoryGB: 4, HourlyPrice: 0.0336, Arch: "arm64"},
+		{Name: "t4g.large", CPU: 2, MemoryGB: 8, HourlyPrice: 0.0672, Arch: "arm64"},
+		{Name: "t4g.xlarge", CPU: 4, MemoryGB: 16, HourlyPrice: 0.1344, Arch: "arm64"},
+		{Name: "t4g.2xlarge", CPU: 8, MemoryGB: 32, HourlyPrice: 0.2688, Arch: "arm64"},
+		{Name: "m6g.large", CPU: 2, MemoryGB: 8, HourlyPrice: 0.077, Arch: "arm64"},
+		{Name: "m6g.xlarge", CPU: 4, MemoryGB: 16, HourlyPrice: 0.154, Arch: "arm64"},
+	},
+	"azurerm": {
+		{Name: "Standard_B1s", CPU: 1, MemoryGB: 1, HourlyPrice: 0.0104, Arch: "amd64"},
+		{Name: "Standard_B1ms", CPU: 1, MemoryGB: 2, HourlyPrice: 0.0207, Arch: "amd64"},
+		{Name: "Standard_B2s", CPU: 2, MemoryGB: 4, HourlyPrice: 0.0416, Arch: "amd64"},
+		{Name: "Standard_B2ms", CPU: 2, MemoryGB: 8, HourlyPrice: 0.0832, Arch: "amd64"},
+		{Name: "Standard_B4ms", CPU: 4, MemoryGB: 16, HourlyPrice: 0.166, Arch: "amd64"},
+		{Name: "Standard_B8ms", CPU: 8, MemoryGB: 32, HourlyPrice: 0.333, Arch: "amd64"},
+		{Name: "Standard_D2s_v3", CPU: 2, MemoryGB: 8, HourlyPrice: 0.096, Arch: "amd64"},
+		{Name: "Standard_D4s_v3", CPU: 4, MemoryGB: 16, HourlyPrice: 0.192, Arch: "amd64"},
+		{Name: "Standard_D8s_v3", CPU: 8, MemoryGB: 32, HourlyPrice: 0.384, Arch: "amd64"},
+		{Name: "Standard_B2pts_v2", CPU: 2, MemoryGB: 1, HourlyPrice: 0.0084, Arch: "arm64"},
+		{Name: "Standard_B2pls_v2", CPU: 2, MemoryGB: 4, HourlyPrice: 0.0336, Arch: "arm64"},
+		{Name: "Standard_B2ps_v2", CPU: 2, MemoryGB: 8, HourlyPrice: 0.0672, Arch: "arm64"},
+		{Name: "Standard_B4ps_v2", CPU: 4, MemoryGB: 16, HourlyPrice: 0.1344, Arch: "arm64"},
+		{Name: "Standard_B8ps_v2", CPU: 8, MemoryGB: 32, HourlyPrice: 0.2688, Arch: "arm64"},
+	},
+	"google": {
+		{Name: "e2-micro", CPU: 2, MemoryGB: 1, HourlyPrice: 0.008474, Arch: "amd64"},
+		{Name: "e2-small", CPU: 2, MemoryGB: 2, HourlyPrice: 0.016948, Arch: "amd64"},
+		{Name: "e2-medium", CPU: 2, MemoryGB: 4, HourlyPrice: 0.033896, Arch: "amd64"},
+		{Name: "e2-standard-2", CPU: 2, MemoryGB: 8, HourlyPrice: 0.067006, Arch: "amd64"},
+		{Name: "e2-standard-4", CPU: 4, MemoryGB: 16, HourlyPrice: 0.134012, Arch: "amd64"},
+		{Name: "e2-standard-8", CPU: 8, MemoryGB: 32, HourlyPrice: 0.268024, Arch: "amd64"},
+		{Name: "n1-standard-1", CPU: 1, MemoryGB: 3.75, HourlyPrice: 0.0475, Arch: "amd64"},
+		{Name: "t2a-standard-1", CPU: 1, MemoryGB: 4, HourlyPrice: 0.0385, Arch: "arm64"},
+		{Name: "t2a-standard-2", CPU: 2, MemoryGB: 8, HourlyPrice: 0.077, Arch: "arm64"},
+		{Name: "t2a-standard-4", CPU: 4, MemoryGB: 16, HourlyPrice: 0.154, Arch: "arm64"},
+		{Name: "t2a-standard-8", CPU: 8, MemoryGB: 32, HourlyPrice: 0.308, Arch: "arm64"},
+	},
+}
+
+// SizeClass returns the minimum capacity of a size class.
+func SizeClass(name string) (Requirements, bool) {
+	requirements, ok := sizeClasses[name]
+	return requirements, ok
+}
+
+// SizeClasses returns the size class names, smallest first.
+func SizeClasses() []string {
+	names := make([]string, 0, len(sizeClasses))
+	for name := range sizeClasses {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return sizeClasses[names[i]].CPU < sizeClasses[names[j]].CPU
+	})
+	return names
+}
+
+// InstanceTypes returns the catalog of a provider type.
+func InstanceTypes(providerType string) []InstanceType {
+	return instanceTypes[providerType]
+}
+
+// LookupInstanceType returns the catalog entry of an instance type of a provider type.
+func LookupInstanceType(providerType, name string) (InstanceType, bool) {
+	for _, instanceType := range instanceTypes[providerType] {
+		if instanceType.Name == name {
+			return instanceType, true
+		}
+	}
+	return InstanceType{}, false
+}
+
+// HourlyPrices returns the hourly price of every instance type of a provider type, keyed by name.
+func HourlyPrices(providerType string) map[string]float64 {
+	prices := make(map[string]float64, len(instanceTypes[providerType]))
+	for _, instanceType := range instanceTypes[providerType] {
+		prices[instanceType.Name] = instanceType.HourlyPrice
+	}
+	return prices
+}
+
+// Cheapest returns the cheapest instance type of a provider type that meets the requirements.
+// Ties go to the smaller instance, then to the name, so the result is stable.
+func Cheapest(providerType string, requirements Requirements) (InstanceType, error) {
+	arch := requirements.Arch
+	if arch == "" {
+		arch = DefaultImage.Arch
+	}
+
+	var best *InstanceType
+	for i, candidate := range instanceTypes[providerType] {
+		if candidate.Arch != arch || candidate.CPU < requirements.CPU || candidate.MemoryGB < requirements.MemoryGB {
+			continue
+		}
+		if best == nil || cheaper(candidate, *best) {
+			best = &instanceTypes[providerType][i]
+		}
+	}
+	if best == nil {
+		return InstanceType{}, fmt.Errorf("no %s %s instance type in the catalog has %d vCPU and %g GB of memory", providerType, arch, requirements.CPU, requirements.MemoryGB)
+	}
+	return *best, nil
+}
+
+func cheaper(a, b InstanceType) bool {
+	if a.HourlyPrice != b.HourlyPrice {
+		return a.HourlyPrice < b.HourlyPrice
+	}
+	if a.CPU != b.CPU {
+		return a.CPU < b.CPU
+	}
+	if a.MemoryGB != b.MemoryGB {
+		return a.MemoryGB < b.MemoryGB
+	}
+	return a.Name < b.Name
+}
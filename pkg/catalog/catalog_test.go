@@ -0,0 +1,99 @@
+package catalog
+
+import "testing"
+
+func TestCheapest(t *testing.T) {
+	tests := []struct {
+		providerType string
+		class        string
+		arch         string
+		want         string
+	}{
+		{"aws", "small", "", "t3.small"},
+		{"aws", "medium", "", "t3.medium"},
+		{"aws", "xlarge", "", "t3.2xlarge"},
+		{"aws", "small", "arm64", "t4g.small"},
+		{"azurerm", "small", "", "Standard_B1ms"},
+		{"azurerm", "large", "", "Standard_B4ms"},
+		{"azurerm", "small", "arm64", "Standard_B2pls_v2"},
+		{"google", "medium", "", "e2-medium"},
+		{"google", "large", "", "e2-standard-4"},
+		{"google", "large", "arm64", "t2a-standard-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.providerType+"/"+tt.class+"/"+tt.arch, func(t *testing.T) {
+			requirements, ok := SizeClass(tt.class)
+			if !ok {
+				t.Fatalf("SizeClass(%q) not found", tt.class)
+			}
+			requirements.Arch = tt.arch
+			got, err := Cheapest(tt.providerType, requirements)
+			if err != nil {
+				t.Fatalf("Cheapest() error = %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("Cheapest() = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+
+	if _, err := Cheapest("aws", Requirements{CPU: 64}); err == nil {
+		t.Error("Cheapest() accepted requirements no instance type meets")
+	}
+	if _, err := Cheapest("google", Requirements{CPU: 16, Arch: "arm64"}); err == nil {
+		t.Error("Cheapest() accepted requirements no arm64 instance type meets")
+	}
+}
+
+func TestSizeClasses(t *testing.T) {
+	want := []string{"small", "medium", "large", "xlarge"}
+	got := SizeClasses()
+	if len(got) != len(want) {
+		t.Fatalf("SizeClasses() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SizeClasses() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLookupImages(t *testing.T) {
+	aws, err := LookupAWSImage(OSImage{OS: "ubuntu", Version: "22.04", Arch: "aarch64"})
+	if err != nil {
+		t.Fatalf("LookupAWSImage() error = %v", err)
+	}
+	if aws.Name != "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-arm64-server-*" || aws.Architecture != "arm64" {
+		t.Errorf("LookupAWSImage() = %+v", aws)
+	}
+
+	azure, err := LookupAzureImage(DefaultImage)
+	if err != nil {
+		t.Fatalf("LookupAzureImage() error = %v", err)
+	}
+	if azure.Offer != "0001-com-ubuntu-server-jammy" || azure.SKU != "22_04-lts-gen2" {
+		t.Errorf("LookupAzureImage() = %+v", azure)
+	}
+
+	google, err := LookupGoogleImage(OSImage{OS: "debian"})
+	if err != nil {
+		t.Fatalf("LookupGoogleImage() error = %v", err)
+	}
+	if google.Project != "debian-cloud" || google.Family != "debian-12" {
+		t.Errorf("LookupGoogleImage() = %+v, want the default Debian version", google)
+	}
+
+	for _, image := range []OSImage{
+		{OS: "windows"},
+		{OS: "ubuntu", Version: "18.04"},
+		{OS: "ubuntu", Arch: "riscv64"},
+	} {
+		if _, err := NormalizeImage(image); err == nil {
+			t.Errorf("NormalizeImage(%+v) accepted an unknown image", image)
+		}
+	}
+	if err := CheckImage("azurerm", OSImage{OS: "debian", Version: "11", Arch: "arm64"}); err == nil {
+		t.Error("CheckImage() accepted an image Azure does not publish")
+	}
+}
@@ -0,0 +1,245 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OSImage identifies an operating system image independently of the cloud.
+type OSImage struct {
+	OS      string
+	Version string
+	// Arch is amd64 or arm64.
+	Arch string
+}
+
+func (i OSImage) String() string {
+	return fmt.Sprintf("%s %s %s", i.OS, i.Version, i.Arch)
+}
+
+// AWSImage is an aws_ami lookup: the newest AMI of Owner whose name matches Name.
+type AWSImage struct {
+	Owner string
+	Name  string
+	// Architecture is the EC2 architecture, x86_64 or arm64.
+	Architecture string
+}
+
+// AzureImage is a marketplace image reference.
+type AzureImage struct {
+	Publisher string
+	Offer     string
+	SKU       string
+}
+
+// GoogleImage is a public image family.
+type GoogleImage struct {
+	Project string
+	Family  string
+}
+
+// osRelease holds the images of one OS version. The AWS name pattern contains %s for the
+// architecture; the Azure and Google images are keyed by architecture and an architecture
+// that is missing is not published on that cloud.
+type osRelease struct {
+	awsOwner string
+	awsName  string
+	azure    map[string]AzureImage
+	google   map[string]GoogleImage
+}
+
+const (
+	canonicalOwner = "099720109477"
+	debianOwner    = "136693071363"
+)
+
+// images is keyed by OS and version.
+var images = map[string]map[string]osRelease{
+	"ubuntu": {
+		"20.04": {
+			awsOwner: canonicalOwner,
+			awsName:  "ubuntu/images/hvm-ssd/ubuntu-focal-20.04-%s-server-*",
+			azure: map[string]AzureImage{
+				"amd64": {Publisher: "Canonical", Offer: "0001-com-ubuntu-server-focal", SKU: "20_04-lts-gen2"},
+				"arm64": {Publisher: "Canonical", Offer: "0001-com-ubuntu-server-focal", SKU: "20_04-lts-arm64"},
+			},
+			google: map[string]GoogleImage{
+				"amd64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2004-lts"},
+				"arm64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2004-lts-arm64"},
+			},
+		},
+		"22.04": {
+			awsOwner: canonicalOwner,
+			awsName:  "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-%s-server-*",
+			azure: map[string]AzureImage{
+				"amd64": {Publisher: "Canonical", Offer: "0001-com-ubuntu-server-jammy", SKU: "22_04-lts-gen2"},
+				"arm64": {Publisher: "Canonical", Offer: "0001-com-ubuntu-server-jammy", SKU: "22_04-lts-arm64"},
+			},
+			google: map[string]GoogleImage{
+				"amd64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2204-lts"},
+				"arm64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2204-lts-arm64"},
+			},
+		},
+		"24.04": {
+			awsOwner: canonicalOwner,
+			awsName:  "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-%s-server-*",
+			azure: map[string]AzureImage{
+				"amd64": {Publisher: "Canonical", Offer: "ubuntu-24_04-lts", SKU: "server"},
+				"arm64": {Publisher: "Canonical", Offer: "ubuntu-24_04-lts", SKU: "server-arm64"},
+			},
+			google: map[string]GoogleImage{
+				"amd64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2404-lts-amd64"},
+				"arm64": {Project: "ubuntu-os-cloud", Family: "ubuntu-2404-lts-arm64"},
+			},
+		},
+	},
+	"debian": {
+		"11": {
+			awsOwner: debianOwner,
+			awsName:  "debian-11-%s-*",
+			azure: map[string]AzureImage{
+				"amd64": {Publisher: "Debian", Offer: "debian-11", SKU: "11-gen2"},
+			},
+			google: map[string]GoogleImage{
+				"amd64": {Project: "debian-cloud", Family: "debian-11"},
+				"arm64": {Project: "debian-cloud", Family: "debian-11-arm64"},
+			},
+		},
+		"12": {
+			awsOwner: debianOwner,
+			awsName:  "debian-12-%s-*",
+			azure: map[string]AzureImage{
+				"amd64": {Publisher: "Debian", Offer: "debian-12", SKU: "12-gen2"},
+				"arm64": {Publisher: "Debian", Offer: "debian-12", SKU: "12-arm64"},
+			},
+			google: map[string]GoogleImage{
+				"amd64": {Project: "debian-cloud", Family: "debian-12"},
+				"arm64": {Project: "debian-cloud", Family: "debian-12-arm64"},
+			},
+		},
+	},
+}
+
+// defaultVersions is the version used when an image names only the OS.
+var defaultVersions = map[string]string{
+	"ubuntu": "22.04",
+	"debian": "12",
+}
+
+// archAliases maps the architecture names used by the clouds and uname to amd64 and arm64.
+var archAliases = map[string]string{
+	"amd64":   "amd64",
+	"x86_64":  "amd64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+}
+
+// awsArchitectures maps architectures to EC2 architecture names.
+var awsArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "arm64",
+}
+
+// DefaultImage is the image of computes that do not select one.
+var DefaultImage = OSImage{OS: "ubuntu", Version: "22.04", Arch: "amd64"}
+
+// NormalizeImage fills in the default version and architecture and checks that the
+// catalog knows the image.
+func NormalizeImage(image OSImage) (OSImage, error) {
+	if image.OS == "" {
+		image.OS = DefaultImage.OS
+	}
+	versions, ok := images[image.OS]
+	if !ok {
+		return OSImage{}, fmt.Errorf("unsupported image os %s (supported: %s)", image.OS, strings.Join(sortedKeys(images), ", "))
+	}
+	if image.Version == "" {
+		image.Version = defaultVersions[image.OS]
+	}
+	if _, ok := versions[image.Version]; !ok {
+		return OSImage{}, fmt.Errorf("unsupported %s version %s (supported: %s)", image.OS, image.Version, strings.Join(sortedKeys(versions), ", "))
+	}
+	if image.Arch == "" {
+		image.Arch = DefaultImage.Arch
+	}
+	arch, ok := archAliases[image.Arch]
+	if !ok {
+		return OSImage{}, fmt.Errorf("unsupported image arch %s (supported: amd64, arm64)", image.Arch)
+	}
+	image.Arch = arch
+	return image, nil
+}
+
+// CheckImage reports whether an image is published on a provider type. Provider types the
+// catalog does not know are not checked.
+func CheckImage(providerType string, image OSImage) error {
+	var err error
+	switch providerType {
+	case "aws":
+		_, err = LookupAWSImage(image)
+	case "azurerm":
+		_, err = LookupAzureImage(image)
+	case "google":
+		_, err = LookupGoogleImage(image)
+	default:
+		_, err = NormalizeImage(image)
+	}
+	return err
+}
+
+// LookupAWSImage returns the AMI lookup for an image.
+func LookupAWSImage(image OSImage) (AWSImage, error) {
+	release, image, err := lookupRelease(image)
+	if err != nil {
+		return AWSImage{}, err
+	}
+	return AWSImage{
+		Owner:        release.awsOwner,
+		Name:         fmt.Sprintf(release.awsName, image.Arch),
+		Architecture: awsArchitectures[image.Arch],
+	}, nil
+}
+
+// LookupAzureImage returns the marketplace image reference for an image.
+func LookupAzureImage(image OSImage) (AzureImage, error) {
+	release, image, err := lookupRelease(image)
+	if err != nil {
+		return AzureImage{}, err
+	}
+	reference, ok := release.azure[image.Arch]
+	if !ok {
+		return AzureImage{}, fmt.Errorf("image %s is not available on azurerm", image)
+	}
+	return reference, nil
+}
+
+// LookupGoogleImage returns the image family for an image.
+func LookupGoogleImage(image OSImage) (GoogleImage, error) {
+	release, image, err := lookupRelease(image)
+	if err != nil {
+		return GoogleImage{}, err
+	}
+	family, ok := release.google[image.Arch]
+	if !ok {
+		return GoogleImage{}, fmt.Errorf("image %s is not available on google", image)
+	}
+	return family, nil
+}
+
+func lookupRelease(image OSImage) (osRelease, OSImage, error) {
+	image, err := NormalizeImage(image)
+	if err != nil {
+		return osRelease{}, OSImage{}, err
+	}
+	return images[image.OS][image.Version], image, nil
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,78 @@
+package compiler
+
+import (
+	"path"
+	"regexp"
+
+	"bold/pkg/config"
+	"bold/pkg/parser"
+)
+
+var unsafeSchemaChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// compileBackend returns the terraform.backend block for the service state, or nil when
+// the state stays in the local build directory. User-supplied config always wins over
+// the defaults derived here.
+func compileBackend(service *parser.Service, cfg *config.Config) map[string]interface{} {
+	state := service.Spec.State
+	if state.Backend == "" {
+		return nil
+	}
+
+	key := state.Key
+	if key == "" {
+		key = service.Metadata.Name
+	}
+
+	config := map[string]interface{}{}
+
+	switch state.Backend {
+	case "local":
+		config["path"] = path.Join(key, "terraform.tfstate")
+	case "s3":
+		config["key"] = path.Join(key, "terraform.tfstate")
+		config["region"] = cfg.Providers.AWS.DefaultRegion
+		if state.LockEnabled() && state.Config["dynamodb_table"] == nil {
+			config["use_lockfile"] = true
+		}
+		if state.Local {
+			// LocalStack and MinIO accept any credentials and need path-style addressing.
+			config["access_key"] = "test"
+			config["secret_key"] = "test"
+			config["use_path_style"] = true
+			config["skip_credentials_validation"] = true
+			config["skip_region_validation"] = true
+			config["skip_requesting_account_id"] = true
+			config["skip_metadata_api_check"] = true
+			config["skip_s3_checksum"] = true
+			config["endpoints"] = map[string]interface{}{
+				"s3":       cfg.Providers.AWS.LocalStackURL,
+				"dynamodb": cfg.Providers.AWS.LocalStackURL,
+			}
+		}
+	case "azurerm":
+		// Blob leases lock the state; there is nothing to configure.
+		config["key"] = path.Join(key, "terraform.tfstate")
+	case "gcs":
+		// GCS locks the state with a lock object next to it.
+		config["prefix"] = key
+	case "http":
+		if state.LockEnabled() {
+			if address, ok := state.Config["address"].(string); ok {
+				config["lock_address"] = address
+				config["unlock_address"] = address
+			}
+		}
+	case "pg":
+		// Postgres locks the state with advisory locks.
+		config["schema_name"] = unsafeSchemaChars.ReplaceAllString(key, "_")
+	}
+
+	for name, value := range state.Config {
+		config[name] = value
+	}
+
+	return map[string]interface{}{
+		state.Backend: config,
+	}
+}
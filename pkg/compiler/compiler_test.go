@@ -0,0 +1,811 @@
+package compiler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bold/pkg/config"
+	"bold/pkg/parser"
+	_ "bold/pkg/provider/builtin"
+)
+
+// compileService compiles the service into a temporary directory and returns the decoded main.tf.json.
+func compileService(t *testing.T, service *parser.Service) map[string]interface{} {
+	t.Helper()
+
+	buildDir := t.TempDir()
+	if _, err := CompileToTofu(service, nil, buildDir); err != nil {
+		t.Fatalf("CompileToTofu failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(buildDir, "main.tf.json"))
+	if err != nil {
+		t.Fatalf("Failed to read generated configuration: %v", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to decode generated configuration: %v", err)
+	}
+	return config
+}
+
+// resourceConfig returns the generated configuration of a single resource, failing the test if it is missing.
+func resourceConfig(t *testing.T, config map[string]interface{}, resourceType, name string) map[string]interface{} {
+	t.Helper()
+
+	resources, _ := config["resource"].(map[string]interface{})
+	byType, ok := resources[resourceType].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected resources of type %s, found none", resourceType)
+	}
+	resource, ok := byType[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected resource %s.%s, found none", resourceType, name)
+	}
+	return resource
+}
+
+func peeredService(providerType string) *parser.Service {
+	return &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "cloud", Type: providerType, Spec: map[string]interface{}{"region": "us-east-1"}},
+		},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "hub", Provider: "cloud", CIDR: "10.0.0.0/16"},
+					{Name: "spoke", Provider: "cloud", CIDR: "10.1.0.0/16"},
+				},
+				Peerings: []parser.Peering{
+					{Name: "hub-spoke", Provider: "cloud", VPCRequester: "hub", VPCAccepter: "spoke"},
+				},
+			},
+		},
+	}
+}
+
+func TestCompilePeeringsAWS(t *testing.T) {
+	config := compileService(t, peeredService("aws"))
+
+	connection := resourceConfig(t, config, "aws_vpc_peering_connection", "hub-spoke")
+	if connection["vpc_id"] != "${aws_vpc.hub.id}" || connection["peer_vpc_id"] != "${aws_vpc.spoke.id}" {
+		t.Errorf("Unexpected peering connection VPCs: %v", connection)
+	}
+
+	accepter := resourceConfig(t, config, "aws_vpc_peering_connection_accepter", "hub-spoke")
+	if accepter["auto_accept"] != true {
+		t.Errorf("Expected accepter to auto accept, got %v", accepter["auto_accept"])
+	}
+
+	route := resourceConfig(t, config, "aws_route", "hub-spoke-requester")
+	if route["destination_cidr_block"] != "10.1.0.0/16" {
+		t.Errorf("Expected requester route to accepter CIDR, got %v", route["destination_cidr_block"])
+	}
+	route = resourceConfig(t, config, "aws_route", "hub-spoke-accepter")
+	if route["destination_cidr_block"] != "10.0.0.0/16" {
+		t.Errorf("Expected accepter route to requester CIDR, got %v", route["destination_cidr_block"])
+	}
+}
+
+func TestCompilePeeringsAzureAndGCP(t *testing.T) {
+	tests := []struct {
+		providerType string
+		resourceType string
+		remoteKey    string
+		remoteValue  string
+	}{
+		{"azurerm", "azurerm_virtual_network_peering", "remote_virtual_network_id", "${azurerm_virtual_network.spoke.id}"},
+		{"google", "google_compute_network_peering", "peer_network", "${google_compute_network.spoke.self_link}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.providerType, func(t *testing.T) {
+			config := compileService(t, peeredService(tt.providerType))
+
+			requester := resourceConfig(t, config, tt.resourceType, "hub-spoke-requester")
+			if requester[tt.remoteKey] != tt.remoteValue {
+				t.Errorf("Expected %s = %s, got %v", tt.remoteKey, tt.remoteValue, requester[tt.remoteKey])
+			}
+			resourceConfig(t, config, tt.resourceType, "hub-spoke-accepter")
+		})
+	}
+}
+
+func TestCompilePeeringsAzureNetworkReferences(t *testing.T) {
+	config := compileService(t, peeredService("azurerm"))
+
+	sides := map[string]string{
+		"hub-spoke-requester": "${azurerm_virtual_network.hub.name}",
+		"hub-spoke-accepter":  "${azurerm_virtual_network.spoke.name}",
+	}
+	for name, want := range sides {
+		peering := resourceConfig(t, config, "azurerm_virtual_network_peering", name)
+		if peering["virtual_network_name"] != want {
+			t.Errorf("%s virtual_network_name = %v, want %s", name, peering["virtual_network_name"], want)
+		}
+	}
+}
+
+func TestCompileStorageVolumes(t *testing.T) {
+	storage := []parser.Storage{
+		{Name: "data", Path: "/data", Size: 50, Type: "gp3", Encrypted: true},
+	}
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "cloud", Type: "aws", Spec: map[string]interface{}{"region": "us-east-1"}},
+		},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "vpc", Provider: "cloud", CIDR: "10.0.0.0/16", Subnets: []parser.Subnet{{Name: "subnet", Zone: "us-east-1a", CIDR: "10.0.1.0/24"}}},
+				},
+				Computes: []parser.Compute{
+					{Name: "db", Type: "ec2", Provider: "cloud", VPC: "vpc", Subnet: "subnet", Storage: storage},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	volume := resourceConfig(t, config, "aws_ebs_volume", "db-data")
+	if volume["encrypted"] != true || volume["type"] != "gp3" || volume["size"] != float64(50) {
+		t.Errorf("Unexpected EBS volume: %v", volume)
+	}
+
+	attachment := resourceConfig(t, config, "aws_volume_attachment", "db-data")
+	if attachment["device_name"] != "/dev/sdf" {
+		t.Errorf("Expected first volume on /dev/sdf, got %v", attachment["device_name"])
+	}
+
+	instance := resourceConfig(t, config, "aws_instance", "db")
+	userData, _ := instance["user_data"].(string)
+	if !strings.Contains(userData, "mount_volume '/data'") {
+		t.Errorf("Expected user_data to mount /data, got:\n%s", userData)
+	}
+}
+
+func TestCompileSourceVPCRules(t *testing.T) {
+	rules := []parser.SecurityGroupRule{
+		{Type: "ingress", Protocol: "tcp", FromPort: 8080, ToPort: 8080, SourceVPC: "vpc-main"},
+		{Type: "egress", Protocol: "tcp", FromPort: 5432, ToPort: 5432, SourceVPC: "vpc-main"},
+	}
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws_cloud", Type: "aws"},
+			{Name: "azure_cloud", Type: "azurerm"},
+			{Name: "gcp_cloud", Type: "google"},
+		},
+		Spec: parser.Spec{
+			KeyPair: parser.KeyPair{Name: "bolt-key"},
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "vpc-main", Provider: "aws_cloud", CIDR: "10.10.0.0/16"},
+				},
+				SecurityGroups: []parser.SecurityGroup{
+					{Name: "app-sg", Provider: "aws_cloud", VPC: "vpc-main", Rules: rules},
+					{Name: "app-nsg", Provider: "azure_cloud", VPC: "vpc-main", Rules: rules},
+					{Name: "app-fw", Provider: "gcp_cloud", VPC: "vpc-main", Rules: rules},
+				},
+				Computes: []parser.Compute{
+					{Name: "app", Type: "azurerm_linux_virtual_machine", Provider: "azure_cloud", VPC: "vpc-main", Subnet: "app-subnet", SecurityGroup: "app-nsg"},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	sg := resourceConfig(t, config, "aws_security_group", "app-sg")
+	ingress := sg["ingress"].([]interface{})[0].(map[string]interface{})
+	if cidrs := ingress["cidr_blocks"].([]interface{}); len(cidrs) != 1 || cidrs[0] != "10.10.0.0/16" {
+		t.Errorf("Expected AWS rule restricted to 10.10.0.0/16, got %v", cidrs)
+	}
+
+	nsgRule := resourceConfig(t, config, "azurerm_network_security_rule", "app-nsg-rule-0")
+	if nsgRule["source_address_prefix"] != "10.10.0.0/16" {
+		t.Errorf("Expected Azure rule restricted to 10.10.0.0/16, got %v", nsgRule["source_address_prefix"])
+	}
+	egressRule := resourceConfig(t, config, "azurerm_network_security_rule", "app-nsg-rule-1")
+	if egressRule["destination_address_prefix"] != "10.10.0.0/16" || egressRule["source_address_prefix"] != "*" {
+		t.Errorf("Expected Azure egress rule to 10.10.0.0/16, got %v", egressRule)
+	}
+	association := resourceConfig(t, config, "azurerm_network_interface_security_group_association", "app-nic")
+	if association["network_security_group_id"] != "${azurerm_network_security_group.app-nsg.id}" {
+		t.Errorf("Expected app-nsg attached to the NIC of app, got %v", association)
+	}
+
+	firewall := resourceConfig(t, config, "google_compute_firewall", "app-fw-rule-0")
+	if ranges := firewall["source_ranges"].([]interface{}); len(ranges) != 1 || ranges[0] != "10.10.0.0/16" {
+		t.Errorf("Expected GCP rule restricted to 10.10.0.0/16, got %v", ranges)
+	}
+}
+
+func TestCompileStateBackend(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Spec: parser.Spec{
+			State: config.StateBackend{
+				Backend: "s3",
+				Key:     "test-service/local",
+				Local:   true,
+				Config: map[string]interface{}{
+					"bucket":    "bolt-state",
+					"endpoints": map[string]interface{}{"s3": "http://localhost:4566"},
+				},
+			},
+		},
+	}
+
+	generated := compileService(t, service)
+	terraform := generated["terraform"].(map[string]interface{})
+	backend, ok := terraform["backend"].(map[string]interface{})["s3"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected an s3 backend, got %v", terraform["backend"])
+	}
+
+	if backend["bucket"] != "bolt-state" || backend["key"] != "test-service/local/terraform.tfstate" {
+		t.Errorf("Unexpected bucket/key: %v/%v", backend["bucket"], backend["key"])
+	}
+	if backend["use_lockfile"] != true {
+		t.Errorf("Expected use_lockfile when locking is enabled, got %v", backend["use_lockfile"])
+	}
+	if backend["use_path_style"] != true {
+		t.Errorf("Expected path-style addressing for a local backend, got %v", backend["use_path_style"])
+	}
+
+	unlocked := false
+	service.Spec.State = config.StateBackend{Backend: "http", Lock: &unlocked, Config: map[string]interface{}{"address": "https://state.example.com/svc"}}
+	generated = compileService(t, service)
+	httpBackend := generated["terraform"].(map[string]interface{})["backend"].(map[string]interface{})["http"].(map[string]interface{})
+	if _, ok := httpBackend["lock_address"]; ok {
+		t.Errorf("Expected no lock_address when locking is disabled, got %v", httpBackend["lock_address"])
+	}
+
+	service.Spec.State = config.StateBackend{}
+	generated = compileService(t, service)
+	if _, ok := generated["terraform"].(map[string]interface{})["backend"]; ok {
+		t.Error("Expected no backend block without spec.state")
+	}
+}
+
+func TestCompileProviderDefaultsFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Providers.AWS.LocalStackURL = "http://localstack:4566"
+	cfg.Providers.Azure.DefaultRegion = "westeurope"
+	cfg.Providers.Azure.DefaultSubscription = "00000000-0000-0000-0000-000000000000"
+	cfg.Providers.GCP.DefaultProject = "bolt-test"
+	cfg.Providers.GCP.DefaultRegion = "europe-west1"
+
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws_local", Type: "aws", Spec: map[string]interface{}{"environment": "local"}},
+			{Name: "azure", Type: "azurerm"},
+			{Name: "gcp", Type: "google"},
+		},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "vnet", Provider: "azure", CIDR: "10.1.0.0/16"},
+				},
+			},
+		},
+	}
+
+	buildDir := t.TempDir()
+	if _, err := CompileToTofu(service, cfg, buildDir); err != nil {
+		t.Fatalf("CompileToTofu failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(buildDir, "main.tf.json"))
+	if err != nil {
+		t.Fatalf("Failed to read generated configuration: %v", err)
+	}
+	var generated map[string]interface{}
+	if err := json.Unmarshal(data, &generated); err != nil {
+		t.Fatalf("Failed to decode generated configuration: %v", err)
+	}
+
+	providers := generated["provider"].(map[string]interface{})
+
+	aws := providers["aws"].(map[string]interface{})
+	if aws["region"] != "us-east-1" {
+		t.Errorf("Expected AWS default region us-east-1, got %v", aws["region"])
+	}
+	endpoints := aws["endpoints"].(map[string]interface{})
+	for _, service := range []string{"ec2", "s3", "dynamodb", "lambda"} {
+		if endpoints[service] != "http://localstack:4566" {
+			t.Errorf("Expected %s endpoint from LocalStackURL, got %v", service, endpoints[service])
+		}
+	}
+
+	azure := providers["azurerm"].(map[string]interface{})
+	if azure["subscription_id"] != cfg.Providers.Azure.DefaultSubscription {
+		t.Errorf("Expected default subscription, got %v", azure["subscription_id"])
+	}
+	vnet := resourceConfig(t, generated, "azurerm_virtual_network", "vnet")
+	if vnet["location"] != "westeurope" {
+		t.Errorf("Expected Azure default region westeurope, got %v", vnet["location"])
+	}
+
+	google := providers["google"].(map[string]interface{})
+	if google["project"] != "bolt-test" || google["region"] != "europe-west1" || google["zone"] != "europe-west1-a" {
+		t.Errorf("Expected GCP project/region/zone from config, got %v/%v/%v", google["project"], google["region"], google["zone"])
+	}
+}
+
+func TestCompileLocalEmulators(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "azurerm_local", Type: "azurerm"},
+			{Name: "google_local", Type: "google", Spec: map[string]interface{}{"environment": "local"}},
+		},
+	}
+
+	providers := compileService(t, service)["provider"].(map[string]interface{})
+
+	azure := providers["azurerm"].(map[string]interface{})
+	if azure["metadata_host"] != "localhost:8443" || azure["skip_provider_registration"] != true {
+		t.Errorf("Expected Azure local mode against the mock ARM endpoint, got %v", azure)
+	}
+	if azure["subscription_id"] == nil {
+		t.Error("Expected a placeholder subscription in Azure local mode")
+	}
+
+	google := providers["google"].(map[string]interface{})
+	if google["storage_custom_endpoint"] != "http://localhost:4443/storage/v1/" {
+		t.Errorf("Expected fake GCS endpoint, got %v", google["storage_custom_endpoint"])
+	}
+	if google["compute_custom_endpoint"] != "http://localhost:8085/compute/v1/" {
+		t.Errorf("Expected compute emulator endpoint, got %v", google["compute_custom_endpoint"])
+	}
+	if google["project"] != "bolt-local" || google["access_token"] == nil {
+		t.Errorf("Expected local project and static token, got project=%v token=%v", google["project"], google["access_token"])
+	}
+}
+
+func TestCompileEKSPrerequisites(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws_local", Type: "aws"},
+		},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "vpc-main", Provider: "aws_local", CIDR: "10.0.0.0/16", Subnets: []parser.Subnet{
+						{Name: "subnet-a", Zone: "us-east-1a", CIDR: "10.0.1.0/24"},
+						{Name: "subnet-b", Zone: "us-east-1b", CIDR: "10.0.2.0/24"},
+					}},
+				},
+				KubernetesClusters: []parser.KubernetesCluster{
+					{Name: "eks-all", Provider: "aws_local", VPC: "vpc-main"},
+					{Name: "eks-one", Provider: "aws_local", VPC: "vpc-main", Spec: map[string]interface{}{"subnets": []interface{}{"subnet-b"}}},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	cluster := resourceConfig(t, config, "aws_eks_cluster", "eks-all")
+	subnets := cluster["vpc_config"].(map[string]interface{})["subnet_ids"].([]interface{})
+	if len(subnets) != 2 || subnets[0] != "${aws_subnet.subnet-a.id}" || subnets[1] != "${aws_subnet.subnet-b.id}" {
+		t.Errorf("Expected all VPC subnets by default, got %v", subnets)
+	}
+
+	nodes := resourceConfig(t, config, "aws_eks_node_group", "eks-one")
+	nodeSubnets := nodes["subnet_ids"].([]interface{})
+	if len(nodeSubnets) != 1 || nodeSubnets[0] != "${aws_subnet.subnet-b.id}" {
+		t.Errorf("Expected the subnets listed in the cluster spec, got %v", nodeSubnets)
+	}
+
+	// Every IAM reference made by the cluster and node group must resolve to a generated resource.
+	resourceConfig(t, config, "aws_iam_role", "eks-all_cluster_role")
+	resourceConfig(t, config, "aws_iam_role", "eks-all_node_role")
+	for _, dependency := range append(cluster["depends_on"].([]interface{}), nodes["depends_on"].([]interface{})...) {
+		parts := strings.SplitN(dependency.(string), ".", 2)
+		resourceConfig(t, config, parts[0], parts[1])
+	}
+
+	role := resourceConfig(t, config, "aws_iam_role", "eks-one_node_role")
+	if !strings.Contains(role["assume_role_policy"].(string), "ec2.amazonaws.com") {
+		t.Errorf("Expected node role trust policy for EC2, got %v", role["assume_role_policy"])
+	}
+}
+
+func TestCompileAKSAndGKENetworking(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "azure", Type: "azurerm", Spec: map[string]interface{}{"region": "westeurope"}},
+			{Name: "gcp", Type: "google", Spec: map[string]interface{}{"region": "europe-west1", "project": "demo"}},
+		},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{Name: "vnet-main", Provider: "azure", CIDR: "10.0.0.0/16", Subnets: []parser.Subnet{
+						{Name: "snet-system", CIDR: "10.0.1.0/24"},
+						{Name: "snet-apps", CIDR: "10.0.2.0/24"},
+					}},
+					{Name: "vpc-main", Provider: "gcp", CIDR: "10.1.0.0/16", Subnets: []parser.Subnet{
+						{Name: "subnet-nodes", CIDR: "10.1.1.0/24"},
+					}},
+				},
+				KubernetesClusters: []parser.KubernetesCluster{
+					{Name: "aks-main", Provider: "azure", VPC: "vnet-main", Spec: map[string]interface{}{
+						"version": "1.29",
+						"node_pools": []interface{}{
+							map[string]interface{}{"name": "system", "node_count": 1},
+							map[string]interface{}{"name": "apps", "subnet": "snet-apps", "min_nodes": 2, "max_nodes": 5},
+						},
+					}},
+					{Name: "gke-main", Provider: "gcp", VPC: "vpc-main", Spec: map[string]interface{}{
+						"min_nodes":         1,
+						"max_nodes":         3,
+						"node_disk_size_gb": 50,
+					}},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	aks := resourceConfig(t, config, "azurerm_kubernetes_cluster", "aks-main")
+	if aks["kubernetes_version"] != "1.29" || aks["location"] != "westeurope" {
+		t.Errorf("Unexpected AKS version or location: %v", aks)
+	}
+	if aks["resource_group_name"] != "${azurerm_resource_group.vnet-main.name}" {
+		t.Errorf("Expected AKS in the VNet resource group, got %v", aks["resource_group_name"])
+	}
+	resourceConfig(t, config, "azurerm_resource_group", "vnet-main")
+
+	defaultPool := aks["default_node_pool"].(map[string]interface{})
+	if defaultPool["name"] != "system" || defaultPool["node_count"] != float64(1) {
+		t.Errorf("Unexpected AKS default node pool: %v", defaultPool)
+	}
+	if defaultPool["vnet_subnet_id"] != "${azurerm_subnet.snet-system.id}" {
+		t.Errorf("Expected default node pool in the first VNet subnet, got %v", defaultPool["vnet_subnet_id"])
+	}
+
+	apps := resourceConfig(t, config, "azurerm_kubernetes_cluster_node_pool", "aks-main-apps")
+	if apps["vnet_subnet_id"] != "${azurerm_subnet.snet-apps.id}" || apps["enable_auto_scaling"] != true ||
+		apps["min_count"] != float64(2) || apps["max_count"] != float64(5) {
+		t.Errorf("Unexpected AKS node pool: %v", apps)
+	}
+
+	gke := resourceConfig(t, config, "google_container_cluster", "gke-main")
+	if gke["location"] != "europe-west1" {
+		t.Errorf("Expected GKE in the provider region, got %v", gke["location"])
+	}
+	if gke["network"] != "${google_compute_network.vpc-main.name}" || gke["subnetwork"] != "${google_compute_subnetwork.subnet-nodes.name}" {
+		t.Errorf("Expected GKE in the manifest network, got %v / %v", gke["network"], gke["subnetwork"])
+	}
+	if _, ok := gke["min_master_version"]; ok {
+		t.Errorf("Expected no master version without spec.version")
+	}
+
+	nodes := resourceConfig(t, config, "google_container_node_pool", "gke-main")
+	autoscaling, ok := nodes["autoscaling"].(map[string]interface{})
+	if !ok || autoscaling["min_node_count"] != float64(1) || autoscaling["max_node_count"] != float64(3) {
+		t.Errorf("Unexpected GKE autoscaling: %v", nodes["autoscaling"])
+	}
+	if _, ok := nodes["node_count"]; ok {
+		t.Errorf("Expected no fixed node_count on an autoscaling pool")
+	}
+	if diskSize := nodes["node_config"].(map[string]interface{})["disk_size_gb"]; diskSize != float64(50) {
+		t.Errorf("Expected the GKE node disk size from node_disk_size_gb, got %v", diskSize)
+	}
+}
+
+func TestCompileSizeClasses(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws", Type: "aws"},
+			{Name: "azure", Type: "azurerm"},
+			{Name: "gcp", Type: "google"},
+		},
+		Spec: parser.Spec{
+			KeyPair: parser.KeyPair{Name: "bolt-key"},
+			Infrastructure: parser.Infrastructure{
+				Computes: []parser.Compute{
+					{Name: "aws-vm", Type: "ec2", Provider: "aws", Subnet: "subnet-a", Spec: map[string]interface{}{"size": "medium"}},
+					{Name: "azure-vm", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a", Spec: map[string]interface{}{"cpu": 4, "memory_gb": 8}},
+					{Name: "gcp-vm", Type: "google_compute_instance", Provider: "gcp", Subnet: "subnet-g", Spec: map[string]interface{}{"size": "small", "memory_gb": 3}},
+					{Name: "pinned", Type: "ec2", Provider: "aws", Subnet: "subnet-a", Spec: map[string]interface{}{"size": "large", "instance_type": "m5.xlarge"}},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	if got := resourceConfig(t, config, "aws_instance", "aws-vm")["instance_type"]; got != "t3.medium" {
+		t.Errorf("size medium on AWS resolved to %v, want t3.medium", got)
+	}
+	if got := resourceConfig(t, config, "azurerm_linux_virtual_machine", "azure-vm")["size"]; got != "Standard_B4ms" {
+		t.Errorf("4 vCPU / 8 GB on Azure resolved to %v, want Standard_B4ms", got)
+	}
+	if got := resourceConfig(t, config, "google_compute_instance", "gcp-vm")["machine_type"]; got != "e2-medium" {
+		t.Errorf("size small with 3 GB on GCP resolved to %v, want e2-medium", got)
+	}
+	if got := resourceConfig(t, config, "aws_instance", "pinned")["instance_type"]; got != "m5.xlarge" {
+		t.Errorf("Expected instance_type to win over size, got %v", got)
+	}
+}
+
+func TestCompileImages(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws", Type: "aws"},
+			{Name: "aws_local", Type: "aws", Spec: map[string]interface{}{"environment": "local"}},
+			{Name: "azure", Type: "azurerm"},
+			{Name: "gcp", Type: "google"},
+		},
+		Spec: parser.Spec{
+			KeyPair: parser.KeyPair{Name: "bolt-key"},
+			Infrastructure: parser.Infrastructure{
+				Computes: []parser.Compute{
+					{Name: "web-1", Type: "ec2", Provider: "aws", Subnet: "subnet-a"},
+					{Name: "web-2", Type: "ec2", Provider: "aws", Subnet: "subnet-a", Image: &parser.Image{Version: "22.04"}},
+					{Name: "arm", Type: "ec2", Provider: "aws", Subnet: "subnet-a", Image: &parser.Image{OS: "debian", Arch: "arm64"},
+						Spec: map[string]interface{}{"size": "small"}},
+					{Name: "pinned", Type: "ec2", Provider: "aws", Subnet: "subnet-a", Image: &parser.Image{ID: "ami-0abc123"}},
+					{Name: "local", Type: "ec2", Provider: "aws_local", Subnet: "subnet-a"},
+					{Name: "azure-vm", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a"},
+					{Name: "azure-urn", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a",
+						Image: &parser.Image{ID: "Canonical:ubuntu-24_04-lts:server:latest"}},
+					{Name: "gcp-vm", Type: "google_compute_instance", Provider: "gcp", Subnet: "subnet-g", Image: &parser.Image{OS: "ubuntu", Version: "24.04"}},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	data, _ := config["data"].(map[string]interface{})
+	amis, _ := data["aws_ami"].(map[string]interface{})
+	if len(amis) != 2 {
+		t.Fatalf("Expected one aws_ami lookup per distinct image, got %v", amis)
+	}
+	lookup, ok := amis["debian-12-arm64"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected an aws_ami lookup for debian-12-arm64, got %v", amis)
+	}
+	if owners := lookup["owners"].([]interface{}); owners[0] != "136693071363" {
+		t.Errorf("Expected the Debian AMI owner, got %v", owners)
+	}
+
+	for name, want := range map[string]string{
+		"web-1":  "${data.aws_ami.ubuntu-22_04-amd64.id}",
+		"web-2":  "${data.aws_ami.ubuntu-22_04-amd64.id}",
+		"arm":    "${data.aws_ami.debian-12-arm64.id}",
+		"pinned": "ami-0abc123",
+		"local":  "ami-local",
+	} {
+		if got := resourceConfig(t, config, "aws_instance", name)["ami"]; got != want {
+			t.Errorf("%s ami = %v, want %s", name, got, want)
+		}
+	}
+	if got := resourceConfig(t, config, "aws_instance", "arm")["instance_type"]; got != "t4g.small" {
+		t.Errorf("arm instance_type = %v, want the arm64 t4g.small", got)
+	}
+
+	reference := resourceConfig(t, config, "azurerm_linux_virtual_machine", "azure-vm")["source_image_reference"].(map[string]interface{})
+	if reference["offer"] != "0001-com-ubuntu-server-jammy" || reference["sku"] != "22_04-lts-gen2" {
+		t.Errorf("Unexpected default Azure image: %v", reference)
+	}
+	reference = resourceConfig(t, config, "azurerm_linux_virtual_machine", "azure-urn")["source_image_reference"].(map[string]interface{})
+	if reference["offer"] != "ubuntu-24_04-lts" || reference["sku"] != "server" {
+		t.Errorf("Expected the URN to select the Azure image, got %v", reference)
+	}
+
+	bootDisk := resourceConfig(t, config, "google_compute_instance", "gcp-vm")["boot_disk"].([]interface{})
+	params := bootDisk[0].(map[string]interface{})["initialize_params"].([]interface{})[0].(map[string]interface{})
+	if params["image"] != "ubuntu-os-cloud/ubuntu-2404-lts-amd64" {
+		t.Errorf("Unexpected GCP image: %v", params["image"])
+	}
+}
+
+func TestCompileUserData(t *testing.T) {
+	setupScript := filepath.Join(t.TempDir(), "setup.sh")
+	if err := os.WriteFile(setupScript, []byte("#!/bin/bash\necho {{ .Compute }} > /etc/role\n"), 0644); err != nil {
+		t.Fatalf("Failed to write user data file: %v", err)
+	}
+
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws", Type: "aws"},
+			{Name: "azure", Type: "azurerm"},
+			{Name: "gcp", Type: "google"},
+		},
+		Spec: parser.Spec{
+			KeyPair: parser.KeyPair{Name: "bolt-key"},
+			Infrastructure: parser.Infrastructure{
+				Computes: []parser.Compute{
+					{
+						Name: "web", Type: "ec2", Provider: "aws", Subnet: "subnet-a",
+						Storage:  []parser.Storage{{Name: "data", Path: "/data", Size: 10, Type: "gp3"}},
+						UserData: &parser.UserData{CloudConfig: "packages: [nginx]\nruncmd:\n  - echo {{ .Service }} in ${HOME}\n"},
+					},
+					{
+						Name: "azure-vm", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a",
+						UserData: &parser.UserData{File: setupScript},
+					},
+					{
+						Name: "gcp-vm", Type: "google_compute_instance", Provider: "gcp", Subnet: "subnet-g",
+						UserData: &parser.UserData{Scripts: []string{"echo ${HOSTNAME} {{ .Subnet }}"}},
+					},
+				},
+			},
+		},
+	}
+
+	config := compileService(t, service)
+
+	userData, _ := resourceConfig(t, config, "aws_instance", "web")["user_data"].(string)
+	mountPart := strings.Index(userData, "mount_volume '/data'")
+	userPart := strings.Index(userData, "Merge-Type: list(append)")
+	if mountPart < 0 || userPart < mountPart {
+		t.Fatalf("Expected the volume mount script before the cloud-config part, got:\n%s", userData)
+	}
+	if !strings.Contains(userData, "#cloud-config\npackages: [nginx]") || !strings.Contains(userData, "echo test-service in $${HOME}") {
+		t.Errorf("Expected the rendered and escaped cloud-config, got:\n%s", userData)
+	}
+
+	customData, _ := resourceConfig(t, config, "azurerm_linux_virtual_machine", "azure-vm")["custom_data"].(string)
+	decoded, err := base64.StdEncoding.DecodeString(customData)
+	if err != nil {
+		t.Fatalf("custom_data is not base64: %v", err)
+	}
+	if string(decoded) != "#!/bin/bash\necho azure-vm > /etc/role\n" {
+		t.Errorf("Expected the rendered user data file as custom_data, got %q", decoded)
+	}
+
+	metadata, _ := resourceConfig(t, config, "google_compute_instance", "gcp-vm")["metadata"].(map[string]interface{})
+	if metadata["user-data"] != "#!/bin/sh\necho $${HOSTNAME} subnet-g" {
+		t.Errorf("Unexpected GCP user-data: %q", metadata["user-data"])
+	}
+}
+
+func TestCompileGeneratedKeyPair(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{
+			{Name: "aws", Type: "aws"},
+			{Name: "azure", Type: "azurerm"},
+			{Name: "gcp", Type: "google"},
+		},
+		Spec: parser.Spec{
+			KeyPair: parser.KeyPair{Name: "bolt-key"},
+			Infrastructure: parser.Infrastructure{
+				Computes: []parser.Compute{
+					{Name: "aws-vm", Type: "ec2", Provider: "aws", Subnet: "subnet-a"},
+					{Name: "azure-vm", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a", Spec: map[string]interface{}{"username": "ops"}},
+					{Name: "gcp-vm", Type: "google_compute_instance", Provider: "gcp", Subnet: "subnet-g"},
+				},
+			},
+		},
+	}
+
+	buildDir := t.TempDir()
+	if _, err := CompileToTofu(service, nil, buildDir); err != nil {
+		t.Fatalf("CompileToTofu failed: %v", err)
+	}
+
+	privateKeyPath := filepath.Join(buildDir, "ssh", "bolt-key")
+	info, err := os.Stat(privateKeyPath)
+	if err != nil {
+		t.Fatalf("Expected a generated private key: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("private key mode = %v, want 0600", info.Mode().Perm())
+	}
+	publicKey, err := os.ReadFile(privateKeyPath + ".pub")
+	if err != nil {
+		t.Fatalf("Expected a generated public key: %v", err)
+	}
+	key := strings.TrimSpace(string(publicKey))
+	if !strings.HasPrefix(key, "ssh-ed25519 ") {
+		t.Errorf("Expected an ed25519 public key, got %q", key)
+	}
+
+	data, err := os.ReadFile(filepath.Join(buildDir, "main.tf.json"))
+	if err != nil {
+		t.Fatalf("Failed to read generated configuration: %v", err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to decode generated configuration: %v", err)
+	}
+
+	if got := resourceConfig(t, config, "aws_key_pair", "bolt-key")["public_key"]; got != key {
+		t.Errorf("aws_key_pair public_key = %v, want the generated key", got)
+	}
+	if got := resourceConfig(t, config, "aws_instance", "aws-vm")["key_name"]; got != "${aws_key_pair.bolt-key.key_name}" {
+		t.Errorf("Unexpected aws_instance key_name: %v", got)
+	}
+	adminKey := resourceConfig(t, config, "azurerm_linux_virtual_machine", "azure-vm")["admin_ssh_key"].([]interface{})[0].(map[string]interface{})
+	if adminKey["username"] != "ops" || adminKey["public_key"] != key {
+		t.Errorf("Unexpected admin_ssh_key: %v", adminKey)
+	}
+	metadata := resourceConfig(t, config, "google_compute_instance", "gcp-vm")["metadata"].(map[string]interface{})
+	if metadata["ssh-keys"] != "boltadmin:"+key {
+		t.Errorf("Unexpected ssh-keys metadata: %v", metadata["ssh-keys"])
+	}
+
+	// A second compile reuses the key pair instead of replacing it.
+	if _, err := CompileToTofu(service, nil, buildDir); err != nil {
+		t.Fatalf("CompileToTofu failed: %v", err)
+	}
+	if again, _ := os.ReadFile(privateKeyPath + ".pub"); string(again) != string(publicKey) {
+		t.Error("Expected the second compile to reuse the generated key pair")
+	}
+}
+
+func TestCompileSecretReferences(t *testing.T) {
+	t.Setenv("AZ_VM_PASS", "correct-horse")
+
+	service := &parser.Service{
+		Metadata:  parser.Metadata{Name: "test-service", Owner: "test-owner"},
+		Providers: []parser.Provider{{Name: "azure", Type: "azurerm"}},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Computes: []parser.Compute{
+					{Name: "vm", Type: "azurerm_linux_virtual_machine", Provider: "azure", Subnet: "snet-a", Spec: map[string]interface{}{
+						"password": map[string]interface{}{"secret": "env://AZ_VM_PASS"},
+					}},
+				},
+			},
+		},
+	}
+
+	buildDir := t.TempDir()
+	result, err := CompileToTofu(service, nil, buildDir)
+	if err != nil {
+		t.Fatalf("CompileToTofu failed: %v", err)
+	}
+	if len(result.Env) != 1 || result.Env[0] != "TF_VAR_secret_env_az_vm_pass=correct-horse" {
+		t.Errorf("Unexpected secret environment: %v", result.Env)
+	}
+
+	data, err := os.ReadFile(filepath.Join(buildDir, "main.tf.json"))
+	if err != nil {
+		t.Fatalf("Failed to read generated configuration: %v", err)
+	}
+	if strings.Contains(string(data), "correct-horse") {
+		t.Error("Expected the secret value to stay out of main.tf.json")
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("Failed to decode generated configuration: %v", err)
+	}
+	vm := resourceConfig(t, config, "azurerm_linux_virtual_machine", "vm")
+	if vm["admin_password"] != "${var.secret_env_az_vm_pass}" || vm["disable_password_authentication"] != false {
+		t.Errorf("Unexpected admin_password settings: %v / %v", vm["admin_password"], vm["disable_password_authentication"])
+	}
+	variable := config["variable"].(map[string]interface{})["secret_env_az_vm_pass"].(map[string]interface{})
+	if variable["sensitive"] != true {
+		t.Errorf("Expected the secret variable to be sensitive, got %v", variable)
+	}
+
+	t.Setenv("AZ_VM_PASS", "")
+	os.Unsetenv("AZ_VM_PASS")
+	if _, err := CompileToTofu(service, nil, t.TempDir()); err == nil {
+		t.Error("Expected an unresolvable secret to fail the compilation")
+	}
+}
@@ -0,0 +1,97 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bold/pkg/logger"
+	"bold/pkg/parser"
+	"bold/pkg/sshkey"
+)
+
+var unsafeKeyNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// resolvePublicKey returns the public key of the service key pair in authorized_keys format,
+// or "" when the service has none. Unless use_existing is set, the key pair is generated on
+// the first compile and read back from its files afterwards, so it stays the same across runs.
+func resolvePublicKey(keyPair parser.KeyPair, boltBuildPath string) (string, error) {
+	if keyPair.Name == "" {
+		return "", nil
+	}
+
+	publicPath, err := parser.ExpandHome(keyPair.PublicKeyFile)
+	if err != nil {
+		return "", err
+	}
+
+	if keyPair.UseExisting {
+		data, err := os.ReadFile(publicPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read public key of key pair %s: %w", keyPair.Name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	privatePath, err := parser.ExpandHome(keyPair.PrivateKeyFile)
+	if err != nil {
+		return "", err
+	}
+	if privatePath == "" {
+		privatePath = filepath.Join(boltBuildPath, "ssh", unsafeKeyNameChars.ReplaceAllString(keyPair.Name, "_"))
+	}
+	if publicPath == "" {
+		publicPath = privatePath + ".pub"
+	}
+
+	if privateKey, err := os.ReadFile(privatePath); err == nil {
+		publicKey, err := sshkey.PublicKey(privateKey, keyPair.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read private key %s: %w", privatePath, err)
+		}
+		if _, err := os.Stat(publicPath); os.IsNotExist(err) {
+			if err := os.WriteFile(publicPath, []byte(publicKey+"\n"), 0644); err != nil {
+				return "", fmt.Errorf("failed to write public key: %w", err)
+			}
+		}
+		return publicKey, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read private key %s: %w", privatePath, err)
+	}
+
+	// A public key without its private key is not overwritten: manifests written before key
+	// generation existed point public_key_file at a key the user already has.
+	if data, err := os.ReadFile(publicPath); err == nil {
+		logger.Warn("Using the existing public key instead of generating a key pair; set use_existing: true to make this explicit", logger.Fields{
+			"key_pair":        keyPair.Name,
+			"public_key_file": publicPath,
+		})
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	privateKey, publicKey, err := sshkey.Generate(keyPair.Name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(privatePath), 0700); err != nil {
+		return "", fmt.Errorf("failed to create private key directory: %w", err)
+	}
+	if err := os.WriteFile(privatePath, privateKey, 0600); err != nil {
+		return "", fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(publicPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create public key directory: %w", err)
+	}
+	if err := os.WriteFile(publicPath, []byte(publicKey+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	logger.Info("Generated SSH key pair", logger.Fields{
+		"key_pair":         keyPair.Name,
+		"private_key_file": privatePath,
+		"public_key_file":  publicPath,
+	})
+	return publicKey, nil
+}
@@ -3,7 +3,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +17,15 @@ type Config struct {
 	Providers ProvidersConfig `yaml:"providers"`
 	Logging   LoggingConfig   `yaml:"logging"`
 	Security  SecurityConfig  `yaml:"security"`
+	// Components locates the components manifests import by name.
+	Components ComponentsConfig `yaml:"components"`
+	// State is the default state backend for manifests without spec.state.
+	State StateBackend `yaml:"state"`
+
+	// Path is the config file that was loaded, empty if none was found.
+	Path string `yaml:"-"`
+	// Profile is the selected profile, empty if none.
+	Profile string `yaml:"-"`
 }
 
 // DefaultsConfig contains default values
@@ -40,12 +52,45 @@ type AWSConfig struct {
 type AzureConfig struct {
 	DefaultRegion       string `yaml:"default_region"`
 	DefaultSubscription string `yaml:"default_subscription"`
+	// EmulatorHost is the host:port of the mock ARM endpoint used in local mode. Its
+	// metadata document decides where storage requests go, normally Azurite.
+	EmulatorHost string `yaml:"emulator_host"`
 }
 
 // GCPConfig contains GCP-specific settings
 type GCPConfig struct {
 	DefaultProject string `yaml:"default_project"`
 	DefaultRegion  string `yaml:"default_region"`
+	// EmulatorURL serves the compute, container, IAM and resource manager APIs in local mode.
+	EmulatorURL string `yaml:"emulator_url"`
+	// StorageEmulatorURL is the fake GCS server used in local mode.
+	StorageEmulatorURL string `yaml:"storage_emulator_url"`
+}
+
+// StateBackend selects where OpenTofu keeps the service state, set by spec.state of a
+// manifest or by the config file as the default. An empty Backend keeps the state in the
+// local build directory.
+type StateBackend struct {
+	Backend string `yaml:"backend"`
+	// Key is the state path inside the backend; it defaults to <service>/<environment>.
+	Key string `yaml:"key,omitempty"`
+	// Lock enables state locking; nil means enabled.
+	Lock *bool `yaml:"lock,omitempty"`
+	// Local points an s3 backend at the S3-compatible emulator (LocalStack, MinIO).
+	Local  bool                   `yaml:"local,omitempty"`
+	Config map[string]interface{} `yaml:"config,omitempty"`
+}
+
+// LockEnabled reports whether state locking is enabled.
+func (s StateBackend) LockEnabled() bool {
+	return s.Lock == nil || *s.Lock
+}
+
+// ComponentsConfig contains the component library settings
+type ComponentsConfig struct {
+	// Library is the directory of the components imports name instead of giving a path;
+	// an import of standard-vpc reads <library>/standard-vpc.yaml.
+	Library string `yaml:"library"`
 }
 
 // LoggingConfig contains logging settings
@@ -53,6 +98,9 @@ type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
 	Output string `yaml:"output"`
+	// RedactKeys are extra field name patterns whose values are redacted from logs and
+	// OpenTofu output, on top of logger.DefaultRedactKeys.
+	RedactKeys []string `yaml:"redact_keys"`
 }
 
 // SecurityConfig contains security settings
@@ -60,36 +108,118 @@ type SecurityConfig struct {
 	RequireConfirmation bool `yaml:"require_confirmation"`
 	MaxRetries          int  `yaml:"max_retries"`
 	TimeoutSeconds      int  `yaml:"timeout_seconds"`
+	// ApplyTimeoutSeconds limits tofu apply and destroy; 0 means no limit, since stopping
+	// them midway leaves resources half created.
+	ApplyTimeoutSeconds int `yaml:"apply_timeout_seconds"`
 }
 
-// LoadConfig loads configuration from file and environment variables
-func LoadConfig(configPath string) (*Config, error) {
-	config := &Config{}
+// LoadConfig loads configuration with the precedence env > profile > file > defaults.
+// Command-line flags are applied by the caller on top of the result.
+//
+// configPath selects the file; when empty the file is discovered (see DiscoverConfigPath).
+// profile selects a named entry under "profiles" in the file; when empty BOLT_PROFILE is used.
+func LoadConfig(configPath, profile string) (*Config, error) {
+	config := Default()
 
-	// Load from file if exists
-	if configPath != "" {
-		if err := loadFromFile(configPath, config); err != nil {
-			return nil, fmt.Errorf("failed to load config file: %w", err)
+	path, err := DiscoverConfigPath(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = os.Getenv("BOLT_PROFILE")
+	}
+
+	if path != "" {
+		if err := loadFromFile(path, profile, config); err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
 		}
+		config.Path = path
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requested but no config file was found", profile)
 	}
+	config.Profile = profile
 
 	// Override with environment variables
 	loadFromEnvironment(config)
 
-	// Set defaults if not specified
-	setDefaults(config)
-
 	return config, nil
 }
 
-// loadFromFile loads configuration from YAML file
-func loadFromFile(path string, config *Config) error {
+// DiscoverConfigPath returns the config file to load. An explicit path (the --config flag)
+// must exist; otherwise BOLT_CONFIG_PATH, ./bolt.yaml, ./config.yaml and
+// $XDG_CONFIG_HOME/bolt/config.yaml are tried in order. It returns "" when none exists.
+func DiscoverConfigPath(explicit string) (string, error) {
+	if explicit == "" {
+		explicit = os.Getenv("BOLT_CONFIG_PATH")
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("config file not found: %w", err)
+		}
+		return explicit, nil
+	}
+
+	candidates := []string{"bolt.yaml", "config.yaml"}
+	if configDir := xdgConfigHome(); configDir != "" {
+		candidates = append(candidates, filepath.Join(configDir, "bolt", "config.yaml"))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", nil
+}
+
+// xdgConfigHome returns $XDG_CONFIG_HOME, falling back to ~/.config.
+func xdgConfigHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+// loadFromFile loads configuration from YAML file, then applies the selected profile on top.
+// Only the keys present in the file or profile replace the current values.
+func loadFromFile(path, profile string, config *Config) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	return yaml.Unmarshal(data, config)
+	var file struct {
+		Config   `yaml:",inline"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}
+	file.Config = *config
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	*config = file.Config
+
+	if profile == "" {
+		return nil
+	}
+
+	node, ok := file.Profiles[profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(sortedKeys(file.Profiles), ", "))
+	}
+	return node.Decode(config)
+}
+
+func sortedKeys(profiles map[string]yaml.Node) []string {
+	keys := make([]string, 0, len(profiles))
+	for key := range profiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // loadFromEnvironment overrides config with environment variables
@@ -120,6 +250,9 @@ func loadFromEnvironment(config *Config) {
 	if env := os.Getenv("BOLT_AZURE_DEFAULT_SUBSCRIPTION"); env != "" {
 		config.Providers.Azure.DefaultSubscription = env
 	}
+	if env := os.Getenv("BOLT_AZURE_EMULATOR_HOST"); env != "" {
+		config.Providers.Azure.EmulatorHost = env
+	}
 
 	// GCP
 	if env := os.Getenv("BOLT_GCP_DEFAULT_PROJECT"); env != "" {
@@ -128,6 +261,12 @@ func loadFromEnvironment(config *Config) {
 	if env := os.Getenv("BOLT_GCP_DEFAULT_REGION"); env != "" {
 		config.Providers.GCP.DefaultRegion = env
 	}
+	if env := os.Getenv("BOLT_GCP_EMULATOR_URL"); env != "" {
+		config.Providers.GCP.EmulatorURL = env
+	}
+	if env := os.Getenv("BOLT_GCP_STORAGE_EMULATOR_URL"); env != "" {
+		config.Providers.GCP.StorageEmulatorURL = env
+	}
 
 	// Logging
 	if env := os.Getenv("BOLT_LOG_LEVEL"); env != "" {
@@ -136,6 +275,14 @@ func loadFromEnvironment(config *Config) {
 	if env := os.Getenv("BOLT_LOG_FORMAT"); env != "" {
 		config.Logging.Format = env
 	}
+	if env := os.Getenv("BOLT_LOG_REDACT_KEYS"); env != "" {
+		config.Logging.RedactKeys = strings.Split(env, ",")
+	}
+
+	// Components
+	if env := os.Getenv("BOLT_COMPONENT_LIBRARY"); env != "" {
+		config.Components.Library = env
+	}
 
 	// Security
 	if env := os.Getenv("BOLT_REQUIRE_CONFIRMATION"); env != "" {
@@ -153,56 +300,46 @@ func loadFromEnvironment(config *Config) {
 			config.Security.TimeoutSeconds = val
 		}
 	}
-}
-
-// setDefaults sets default values if not specified
-func setDefaults(config *Config) {
-	// Defaults
-	if config.Defaults.Region == "" {
-		config.Defaults.Region = "us-east-1"
-	}
-	if config.Defaults.Environment == "" {
-		config.Defaults.Environment = "local"
-	}
-
-	// AWS
-	if config.Providers.AWS.LocalStackURL == "" {
-		config.Providers.AWS.LocalStackURL = "http://localhost:4566"
-	}
-	if config.Providers.AWS.DefaultRegion == "" {
-		config.Providers.AWS.DefaultRegion = "us-east-1"
-	}
-
-	// Azure
-	if config.Providers.Azure.DefaultRegion == "" {
-		config.Providers.Azure.DefaultRegion = "eastus"
-	}
-
-	// GCP
-	if config.Providers.GCP.DefaultRegion == "" {
-		config.Providers.GCP.DefaultRegion = "us-central1"
-	}
-
-	// Logging
-	if config.Logging.Level == "" {
-		config.Logging.Level = "info"
-	}
-	if config.Logging.Format == "" {
-		config.Logging.Format = "text"
-	}
-	if config.Logging.Output == "" {
-		config.Logging.Output = "stdout"
+	if env := os.Getenv("BOLT_APPLY_TIMEOUT_SECONDS"); env != "" {
+		if val, err := strconv.Atoi(env); err == nil {
+			config.Security.ApplyTimeoutSeconds = val
+		}
 	}
+}
 
-	// Security
-	if config.Security.MaxRetries == 0 {
-		config.Security.MaxRetries = 3
-	}
-	if config.Security.TimeoutSeconds == 0 {
-		config.Security.TimeoutSeconds = 300
-	}
-	if !config.Security.RequireConfirmation {
-		config.Security.RequireConfirmation = true
+// Default returns the built-in defaults. File, profile and environment values are
+// layered on top, so any of them can override a default, including disabling a boolean.
+func Default() *Config {
+	return &Config{
+		Defaults: DefaultsConfig{
+			Region:      "us-east-1",
+			Environment: "local",
+		},
+		Providers: ProvidersConfig{
+			AWS: AWSConfig{
+				LocalStackURL: "http://localhost:4566",
+				DefaultRegion: "us-east-1",
+			},
+			Azure: AzureConfig{
+				DefaultRegion: "eastus",
+				EmulatorHost:  "localhost:8443",
+			},
+			GCP: GCPConfig{
+				DefaultRegion:      "us-central1",
+				EmulatorURL:        "http://localhost:8085",
+				StorageEmulatorURL: "http://localhost:4443",
+			},
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+			Output: "stdout",
+		},
+		Security: SecurityConfig{
+			RequireConfirmation: true,
+			MaxRetries:          3,
+			TimeoutSeconds:      300,
+		},
 	}
 }
 
@@ -218,11 +355,14 @@ func (c *Config) GetProviderConfig(providerType string) map[string]interface{} {
 		return map[string]interface{}{
 			"default_region":       c.Providers.Azure.DefaultRegion,
 			"default_subscription": c.Providers.Azure.DefaultSubscription,
+			"emulator_host":        c.Providers.Azure.EmulatorHost,
 		}
 	case "google":
 		return map[string]interface{}{
-			"default_project": c.Providers.GCP.DefaultProject,
-			"default_region":  c.Providers.GCP.DefaultRegion,
+			"default_project":      c.Providers.GCP.DefaultProject,
+			"default_region":       c.Providers.GCP.DefaultRegion,
+			"emulator_url":         c.Providers.GCP.EmulatorURL,
+			"storage_emulator_url": c.Providers.GCP.StorageEmulatorURL,
 		}
 	default:
 		return map[string]interface{}{}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+providers:
+  aws:
+    default_region: eu-west-1
+security:
+  require_confirmation: false
+  max_retries: 5
+profiles:
+  prod:
+    providers:
+      aws:
+        default_region: eu-central-1
+    security:
+      require_confirmation: true
+`
+
+func writeConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bolt.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfig(t)
+	t.Setenv("BOLT_PROFILE", "")
+
+	cfg, err := LoadConfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Security.RequireConfirmation {
+		t.Error("require_confirmation: false in the file was overridden")
+	}
+	if cfg.Providers.AWS.DefaultRegion != "eu-west-1" || cfg.Security.MaxRetries != 5 {
+		t.Errorf("file values not applied: region=%s max_retries=%d", cfg.Providers.AWS.DefaultRegion, cfg.Security.MaxRetries)
+	}
+	if cfg.Security.TimeoutSeconds != 300 || cfg.Providers.AWS.LocalStackURL != "http://localhost:4566" {
+		t.Errorf("defaults not kept for keys missing from the file: timeout=%d localstack=%s", cfg.Security.TimeoutSeconds, cfg.Providers.AWS.LocalStackURL)
+	}
+
+	cfg, err = LoadConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadConfig(prod) error = %v", err)
+	}
+	if cfg.Providers.AWS.DefaultRegion != "eu-central-1" || !cfg.Security.RequireConfirmation {
+		t.Errorf("profile not applied: region=%s require_confirmation=%v", cfg.Providers.AWS.DefaultRegion, cfg.Security.RequireConfirmation)
+	}
+	if cfg.Security.MaxRetries != 5 {
+		t.Errorf("file value lost under profile: max_retries=%d", cfg.Security.MaxRetries)
+	}
+
+	t.Setenv("BOLT_AWS_DEFAULT_REGION", "ap-southeast-1")
+	cfg, err = LoadConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadConfig(prod) error = %v", err)
+	}
+	if cfg.Providers.AWS.DefaultRegion != "ap-southeast-1" {
+		t.Errorf("environment did not override profile: region=%s", cfg.Providers.AWS.DefaultRegion)
+	}
+
+	if _, err := LoadConfig(path, "staging"); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestDiscoverConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("BOLT_CONFIG_PATH", "")
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	if path, err := DiscoverConfigPath(""); err != nil || path != "" {
+		t.Errorf("DiscoverConfigPath() = %q, %v; want no file", path, err)
+	}
+
+	xdgPath := filepath.Join(dir, "xdg", "bolt", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(xdgPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{xdgPath, "config.yaml", "bolt.yaml"} {
+		if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if path, err := DiscoverConfigPath(""); err != nil || path != file {
+			t.Errorf("DiscoverConfigPath() = %q, %v; want %q", path, err, file)
+		}
+	}
+
+	if _, err := DiscoverConfigPath(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("missing explicit config file accepted")
+	}
+}
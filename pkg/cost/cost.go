@@ -1,7 +1,9 @@
 package cost
 
 import (
+	"bold/pkg/catalog"
 	"bold/pkg/parser"
+	"bold/pkg/provider"
 	"fmt"
 	"strings"
 )
@@ -32,17 +34,14 @@ type PricingData struct {
 
 var defaultPricing = PricingData{
 	AWS: map[string]map[string]float64{
-		"ec2": map[string]float64{
-			"t2.micro": 0.0116,
-			"t3.micro": 0.0104,
-			"t2.small": 0.023,
-			"t3.small": 0.0208,
-			"m5.large": 0.096,
-			"c5.large": 0.085,
-		},
+		// Instance prices come from the catalog the compiler resolves size classes with.
+		"ec2": catalog.HourlyPrices("aws"),
 		"storage": map[string]float64{
 			"gp2": 0.10,
+			"gp3": 0.08,
 			"io1": 0.125,
+			"st1": 0.045,
+			"sc1": 0.015,
 		},
 		"network": map[string]float64{
 			"vpc":    0.0,
@@ -51,14 +50,11 @@ var defaultPricing = PricingData{
 		},
 	},
 	Azure: map[string]map[string]float64{
-		"vm": map[string]float64{
-			"Standard_B1s":    0.0104,
-			"Standard_B2s":    0.0416,
-			"Standard_D2s_v3": 0.096,
-		},
+		"vm": catalog.HourlyPrices("azurerm"),
 		"storage": map[string]float64{
-			"Standard_LRS": 0.0184,
-			"Premium_LRS":  0.12288,
+			"Standard_LRS":    0.0184,
+			"StandardSSD_LRS": 0.075,
+			"Premium_LRS":     0.12288,
 		},
 		"network": map[string]float64{
 			"vnet":   0.0,
@@ -66,14 +62,10 @@ var defaultPricing = PricingData{
 		},
 	},
 	GCP: map[string]map[string]float64{
-		"compute": map[string]float64{
-			"e2-micro":      0.008474,
-			"e2-small":      0.016948,
-			"e2-medium":     0.033896,
-			"n1-standard-1": 0.0475,
-		},
+		"compute": catalog.HourlyPrices("google"),
 		"storage": map[string]float64{
 			"pd-standard": 0.04,
+			"pd-balanced": 0.10,
 			"pd-ssd":      0.17,
 		},
 		"network": map[string]float64{
@@ -109,7 +101,7 @@ func EstimateCosts(service *parser.Service) *CostReport {
 	}
 
 	for _, compute := range service.Spec.Infrastructure.Computes {
-		estimate := estimateComputeCost(compute)
+		estimate := estimateComputeCost(service, compute)
 		if estimate != nil {
 			report.Estimates = append(report.Estimates, *estimate)
 			report.TotalMonthlyCost += estimate.MonthlyCost
@@ -118,7 +110,7 @@ func EstimateCosts(service *parser.Service) *CostReport {
 	}
 
 	for _, cluster := range service.Spec.Infrastructure.KubernetesClusters {
-		estimate := estimateKubernetesCost(cluster)
+		estimate := estimateKubernetesCost(service, cluster)
 		if estimate != nil {
 			report.Estimates = append(report.Estimates, *estimate)
 			report.TotalMonthlyCost += estimate.MonthlyCost
@@ -165,55 +157,42 @@ func estimateSecurityGroupCost(sg parser.SecurityGroup) *CostEstimate {
 	}
 }
 
-func estimateComputeCost(compute parser.Compute) *CostEstimate {
-	provider := strings.ToLower(compute.Provider)
+func estimateComputeCost(service *parser.Service, compute parser.Compute) *CostEstimate {
+	providerType, _, local := resolveProvider(service, compute.Provider)
 
 	var hourlyCost float64
 	var instanceType string
-
-	if spec, ok := compute.Spec["instance_type"].(string); ok {
-		instanceType = spec
-	} else if spec, ok := compute.Spec["size"].(string); ok {
-		instanceType = spec
-	} else if spec, ok := compute.Spec["machine_type"].(string); ok {
-		instanceType = spec
-	} else {
-		switch compute.Type {
-		case "ec2":
-			instanceType = "t3.micro"
-		case "azurerm_linux_virtual_machine":
-			instanceType = "Standard_B1s"
-		case "google_compute_instance":
-			instanceType = "e2-micro"
-		}
+	var rootDiskSize int
+
+	// The typed spec applies the same defaults as the compiler; it is missing only for
+	// compute types no backend supports.
+	var sizing string
+	if compute.TypedSpec != nil {
+		instanceType = compute.TypedSpec.InstanceSize()
+		rootDiskSize = compute.TypedSpec.RootDiskSizeGB()
+	}
+	if sized, ok := compute.TypedSpec.(parser.SizedSpec); ok {
+		sizing = describeSizing(providerType, *sized.Sizing(), instanceType)
+	}
+	if rootDiskSize == 0 {
+		rootDiskSize = 20
+	}
+	storageGB := rootDiskSize
+	for _, volume := range compute.Storage {
+		storageGB += volume.Size
 	}
 
 	hourlyCost = 0.0
 	storageCost := 0.0
 
-	if provider == "aws_local" || provider == "azurerm_local" || provider == "google_local" {
-		hourlyCost = 0.0
-		storageCost = 0.0
-	} else {
-		switch provider {
-		case "aws":
-			if pricing, exists := defaultPricing.AWS["ec2"][instanceType]; exists {
-				hourlyCost = pricing
-			}
-		case "azurerm":
-			if pricing, exists := defaultPricing.Azure["vm"][instanceType]; exists {
-				hourlyCost = pricing
-			}
-		case "google":
-			if pricing, exists := defaultPricing.GCP["compute"][instanceType]; exists {
-				hourlyCost = pricing
-			}
+	if !local {
+		if pricing, exists := instancePricing(providerType)[instanceType]; exists {
+			hourlyCost = pricing
 		}
 
-		if rootDiskSize, ok := compute.Spec["root_disk_size_gb"].(int); ok {
-			storageCost = float64(rootDiskSize) * 0.10
-		} else {
-			storageCost = 20 * 0.10
+		storageCost = float64(rootDiskSize) * storagePricePerGB(providerType, "")
+		for _, volume := range compute.Storage {
+			storageCost += float64(volume.Size) * storagePricePerGB(providerType, volume.Type)
 		}
 	}
 
@@ -228,72 +207,77 @@ func estimateComputeCost(compute parser.Compute) *CostEstimate {
 		Currency:     "USD",
 		Details: map[string]interface{}{
 			"instance_type": instanceType,
+			"sizing":        sizing,
 			"vpc":           compute.VPC,
 			"subnet":        compute.Subnet,
-			"storage_gb":    20,
-			"environment":   getEnvironmentFromProvider(provider),
+			"storage_gb":    storageGB,
+			"volumes":       len(compute.Storage),
+			"environment":   environmentName(local),
 		},
 	}
 
 	return estimate
 }
 
-func estimateKubernetesCost(cluster parser.KubernetesCluster) *CostEstimate {
-	provider := strings.ToLower(cluster.Provider)
+// defaultStorageTypes is the disk type used to price volumes that do not declare one, such as root disks.
+var defaultStorageTypes = map[string]string{
+	"aws":     "gp2",
+	"azurerm": "Standard_LRS",
+	"google":  "pd-standard",
+}
+
+// storagePricePerGB returns the monthly price per GB of a disk type, falling back to
+// the provider's default disk type when the type is empty or unknown.
+func storagePricePerGB(providerType, storageType string) float64 {
+	var pricing map[string]float64
+	switch providerType {
+	case "aws":
+		pricing = defaultPricing.AWS["storage"]
+	case "azurerm":
+		pricing = defaultPricing.Azure["storage"]
+	case "google":
+		pricing = defaultPricing.GCP["storage"]
+	default:
+		return 0.10
+	}
+
+	if price, exists := pricing[storageType]; exists {
+		return price
+	}
+	return pricing[defaultStorageTypes[providerType]]
+}
+
+func estimateKubernetesCost(service *parser.Service, cluster parser.KubernetesCluster) *CostEstimate {
+	providerType, info, local := resolveProvider(service, cluster.Provider)
 
 	var monthlyCost float64
-	var clusterType string
-	var nodeCount int
-	var nodeType string
-
-	if spec, ok := cluster.Spec["node_count"].(int); ok {
-		nodeCount = spec
-	} else {
-		nodeCount = 2
-	}
-
-	if spec, ok := cluster.Spec["node_type"].(string); ok {
-		nodeType = spec
-	} else if spec, ok := cluster.Spec["node_size"].(string); ok {
-		nodeType = spec
-	} else if spec, ok := cluster.Spec["machine_type"].(string); ok {
-		nodeType = spec
-	}
-
-	if provider == "aws_local" || provider == "azurerm_local" || provider == "google_local" {
-		monthlyCost = 0.0
-	} else {
-		switch provider {
-		case "aws":
-			clusterType = "eks"
-			if nodeType == "" {
-				nodeType = "t3.medium"
-			}
-			if pricing, exists := defaultPricing.AWS["ec2"][nodeType]; exists {
-				monthlyCost = pricing * 730 * float64(nodeCount)
-			}
-			monthlyCost += 73.0 // EKS control plane cost per month
-		case "azurerm":
-			clusterType = "aks"
-			if nodeType == "" {
-				nodeType = "Standard_B2s"
-			}
-			if pricing, exists := defaultPricing.Azure["vm"][nodeType]; exists {
-				monthlyCost = pricing * 730 * float64(nodeCount)
-			}
-			monthlyCost += 73.0 // AKS control plane cost per month
-		case "google":
-			clusterType = "gke"
-			if nodeType == "" {
-				nodeType = "e2-medium"
-			}
-			if pricing, exists := defaultPricing.GCP["compute"][nodeType]; exists {
-				monthlyCost = pricing * 730 * float64(nodeCount)
+	var pools []parser.NodePool
+	if cluster.TypedSpec != nil {
+		pools = cluster.TypedSpec.NodePools()
+	}
+
+	// Autoscaling pools are priced at their minimum size.
+	nodeCount := 0
+	var nodeTypes []string
+	for _, pool := range pools {
+		nodes := pool.Count
+		if pool.Autoscaling() {
+			nodes = pool.Min
+		}
+		nodeCount += nodes
+		nodeTypes = append(nodeTypes, pool.Size)
+
+		if !local {
+			if pricing, exists := instancePricing(providerType)[pool.Size]; exists {
+				monthlyCost += pricing * 730 * float64(nodes)
 			}
-			monthlyCost += 73.0 // GKE control plane cost per month
 		}
 	}
 
+	if !local && providerType != "" {
+		monthlyCost += 73.0 // Managed control plane cost per month
+	}
+
 	estimate := &CostEstimate{
 		ResourceType: "kubernetes",
 		ResourceName: cluster.Name,
@@ -302,19 +286,43 @@ func estimateKubernetesCost(cluster parser.KubernetesCluster) *CostEstimate {
 		HourlyCost:   monthlyCost / 730,
 		Currency:     "USD",
 		Details: map[string]interface{}{
-			"cluster_type": clusterType,
+			"cluster_type": info.ClusterType,
 			"vpc":          cluster.VPC,
 			"node_count":   nodeCount,
-			"node_type":    nodeType,
-			"environment":  getEnvironmentFromProvider(provider),
+			"node_type":    strings.Join(nodeTypes, ", "),
+			"node_pools":   len(pools),
+			"environment":  environmentName(local),
 		},
 	}
 
 	return estimate
 }
 
-func getEnvironmentFromProvider(provider string) string {
-	if strings.Contains(provider, "local") {
+// resolveProvider maps a manifest provider reference to its provider type, backend
+// description and whether it targets a local emulator (which costs nothing).
+func resolveProvider(service *parser.Service, name string) (string, provider.Info, bool) {
+	p, backend, ok := provider.Resolve(service, name)
+	if !ok {
+		return "", provider.Info{}, strings.Contains(strings.ToLower(name), "local")
+	}
+	return p.Type, backend.Info(), provider.IsLocal(p)
+}
+
+// instancePricing returns the hourly price table for compute instances of a provider type.
+func instancePricing(providerType string) map[string]float64 {
+	switch providerType {
+	case "aws":
+		return defaultPricing.AWS["ec2"]
+	case "azurerm":
+		return defaultPricing.Azure["vm"]
+	case "google":
+		return defaultPricing.GCP["compute"]
+	}
+	return nil
+}
+
+func environmentName(local bool) string {
+	if local {
 		return "local"
 	}
 	return "production"
@@ -338,8 +346,8 @@ func FormatCostReport(report *CostReport) string {
 	output.WriteString("\n📋 Detailed Resource Costs:\n")
 	output.WriteString("---------------------------\n")
 	for _, estimate := range report.Estimates {
-		output.WriteString(fmt.Sprintf("• %s (%s): $%.2f/month\n",
-			estimate.ResourceName, estimate.ResourceType, estimate.MonthlyCost))
+		output.WriteString(fmt.Sprintf("• %s (%s%s): $%.2f/month\n",
+			estimate.ResourceName, estimate.ResourceType, instanceLabel(estimate), estimate.MonthlyCost))
 	}
 
 	output.WriteString("\n💡 Cost Optimization Tips:\n")
@@ -352,6 +360,99 @@ func FormatCostReport(report *CostReport) string {
 	return output.String()
 }
 
+// FormatCostDiff compares the cost of a manifest with an environment overlay applied to the
+// cost of the base manifest, resource by resource.
+func FormatCostDiff(base, overlay *CostReport, env string) string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("💰 Cost Difference: %s vs base\n", env))
+	output.WriteString("============================\n\n")
+
+	width := max(len("Base Monthly Cost:"), len(env+" Monthly Cost:")) + 1
+	output.WriteString(fmt.Sprintf("%-*s$%.2f USD\n", width, "Base Monthly Cost:", base.TotalMonthlyCost))
+	output.WriteString(fmt.Sprintf("%-*s$%.2f USD\n", width, env+" Monthly Cost:", overlay.TotalMonthlyCost))
+	output.WriteString(fmt.Sprintf("%-*s%s/month\n\n", width, "Difference:", signedCost(overlay.TotalMonthlyCost-base.TotalMonthlyCost)))
+
+	baseCosts := make(map[string]CostEstimate)
+	for _, estimate := range base.Estimates {
+		baseCosts[estimate.ResourceType+"/"+estimate.ResourceName] = estimate
+	}
+
+	var changes []string
+	for _, estimate := range overlay.Estimates {
+		key := estimate.ResourceType + "/" + estimate.ResourceName
+		baseEstimate, existed := baseCosts[key]
+		delete(baseCosts, key)
+
+		switch {
+		case !existed:
+			changes = append(changes, fmt.Sprintf("+ %s (%s%s): %s/month",
+				estimate.ResourceName, estimate.ResourceType, instanceLabel(estimate), signedCost(estimate.MonthlyCost)))
+		case estimate.MonthlyCost != baseEstimate.MonthlyCost || instanceLabel(estimate) != instanceLabel(baseEstimate):
+			changes = append(changes, fmt.Sprintf("~ %s (%s%s): $%.2f → $%.2f/month (%s)",
+				estimate.ResourceName, estimate.ResourceType, instanceLabel(estimate),
+				baseEstimate.MonthlyCost, estimate.MonthlyCost, signedCost(estimate.MonthlyCost-baseEstimate.MonthlyCost)))
+		}
+	}
+	for _, estimate := range base.Estimates {
+		if _, removed := baseCosts[estimate.ResourceType+"/"+estimate.ResourceName]; removed {
+			changes = append(changes, fmt.Sprintf("- %s (%s%s): %s/month",
+				estimate.ResourceName, estimate.ResourceType, instanceLabel(estimate), signedCost(-estimate.MonthlyCost)))
+		}
+	}
+
+	output.WriteString("📋 Changed Resources:\n")
+	output.WriteString("---------------------\n")
+	if len(changes) == 0 {
+		output.WriteString("No cost changes\n")
+	}
+	for _, change := range changes {
+		output.WriteString(change + "\n")
+	}
+
+	return output.String()
+}
+
+// signedCost formats a monthly cost difference with its sign, e.g. +$12.50.
+func signedCost(amount float64) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("+$%.2f", amount)
+}
+
+// describeSizing describes the size class and cpu/memory_gb requirements a compute's instance
+// type was resolved from, or returns "" when the instance type was set directly.
+func describeSizing(providerType string, sizing parser.ComputeSizing, instanceType string) string {
+	if resolved, err := sizing.Resolve(providerType); err != nil || resolved != instanceType {
+		return ""
+	}
+
+	var parts []string
+	if sizing.IsClass() {
+		parts = append(parts, sizing.Size)
+	}
+	if sizing.CPU > 0 {
+		parts = append(parts, fmt.Sprintf("%d vCPU", sizing.CPU))
+	}
+	if sizing.MemoryGB > 0 {
+		parts = append(parts, fmt.Sprintf("%g GB", sizing.MemoryGB))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// instanceLabel returns ", <instance type>" for compute estimates, noting the sizing it was resolved from.
+func instanceLabel(estimate CostEstimate) string {
+	instanceType, _ := estimate.Details["instance_type"].(string)
+	if instanceType == "" {
+		return ""
+	}
+	if sizing, _ := estimate.Details["sizing"].(string); sizing != "" {
+		return fmt.Sprintf(", %s → %s", sizing, instanceType)
+	}
+	return ", " + instanceType
+}
+
 func GetPricingData() *PricingData {
 	return &defaultPricing
 }
@@ -3,95 +3,308 @@ package engine
 import (
 	"bold/pkg/errors"
 	"bold/pkg/logger"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 // Engine adalah interface universal untuk semua engine eksekusi (Tofu, Ansible, dll).
+// Semua method menerima context sehingga eksekusi bisa dibatalkan (timeout atau Ctrl-C).
 type Engine interface {
-	Init() error
-	Plan() error
-	Apply() error
-	PlanDestroy() error
-	Destroy() error
+	Init(ctx context.Context) error
+	Plan(ctx context.Context) error
+	ShowPlan(ctx context.Context) ([]byte, error)
+	Apply(ctx context.Context) error
+	PlanDestroy(ctx context.Context) error
+	Destroy(ctx context.Context) error
 }
 
 // OpenTofuEngine adalah implementasi Engine untuk OpenTofu.
 type OpenTofuEngine struct {
 	WorkDir string
+	// Timeout membatasi durasi satu kali eksekusi init, plan dan show; 0 berarti tanpa batas.
+	Timeout time.Duration
+	// ApplyTimeout membatasi durasi satu kali apply atau destroy; 0 berarti tanpa batas.
+	// Apply dipisahkan dari Timeout karena menghentikannya di tengah jalan meninggalkan
+	// resource setengah jadi dan state lock yang harus dilepas manual.
+	ApplyTimeout time.Duration
+	// MaxRetries adalah jumlah percobaan ulang untuk kegagalan sementara.
+	MaxRetries int
+	// NoLock menonaktifkan state locking (-lock=false) untuk backend yang tidak mendukungnya.
+	NoLock bool
+	// Env ditambahkan ke environment proses tofu, mis. TF_VAR_* yang membawa secret
+	// sehingga nilainya tidak pernah ditulis ke direktori build.
+	Env []string
 }
 
-func (t *OpenTofuEngine) runCommand(args ...string) error {
-	cmd := exec.Command("tofu", args...)
-	cmd.Dir = t.WorkDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// lockArgs menyisipkan -lock=false tepat setelah subcommand, sebelum argumen posisi seperti file plan.
+func (t *OpenTofuEngine) lockArgs(args ...string) []string {
+	if !t.NoLock {
+		return args
+	}
+	return append([]string{args[0], "-lock=false"}, args[1:]...)
+}
 
-	commandStr := fmt.Sprintf("tofu %s", strings.Join(args, " "))
-	logger.Info("Executing OpenTofu command", logger.Fields{
-		"command":  commandStr,
-		"work_dir": t.WorkDir,
+// retryBaseDelay adalah jeda sebelum percobaan ulang pertama; jeda berlipat dua setiap percobaan.
+// Berupa variabel agar test bisa menghapus jeda.
+var retryBaseDelay = 2 * time.Second
+
+const (
+	retryMaxDelay = 30 * time.Second
+	// killGracePeriod adalah waktu tunggu setelah SIGTERM sebelum proses dihentikan paksa.
+	killGracePeriod = 10 * time.Second
+)
+
+// planArgs dan destroyPlanArgs membuat tfplan yang dijalankan oleh Apply dan Destroy.
+var (
+	planArgs        = []string{"plan", "-out=tfplan"}
+	destroyPlanArgs = []string{"plan", "-destroy", "-out=tfplan"}
+)
+
+// transientErrors adalah potongan stderr yang menandakan kegagalan sementara
+// (state lock sedang dipakai, rate limit provider) yang layak dicoba ulang.
+var transientErrors = []string{
+	"error acquiring the state lock",
+	"conditionalcheckfailedexception",
+	"requestlimitexceeded",
+	"throttling",
+	"too many requests",
+	"rate exceeded",
+	"rate limit",
+	"toomanyrequests",
+	"status code: 429",
+	"statuscode=429",
+	"connection reset by peer",
+}
+
+// isTransient menentukan apakah stderr tofu menunjukkan kegagalan yang bisa dicoba ulang.
+func isTransient(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, pattern := range transientErrors {
+		if strings.Contains(stderr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay menghitung jeda backoff eksponensial untuk percobaan ulang ke-attempt (mulai dari 0).
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	// Overflow membuat hasil geser lebih kecil dari jeda awal.
+	if delay < retryBaseDelay || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// command adalah satu perintah tofu beserta cara menjalankannya.
+type command struct {
+	args []string
+	// capture mengembalikan stdout alih-alih mencetaknya.
+	capture bool
+	// timeout membatasi durasi satu kali eksekusi; 0 berarti tanpa batas.
+	timeout time.Duration
+	// replan dijalankan sebelum setiap percobaan ulang untuk membuat ulang tfplan: apply yang
+	// gagal di tengah jalan sudah mengubah state, sehingga tfplan lama ditolak sebagai stale.
+	replan []string
+}
+
+func (t *OpenTofuEngine) runCommand(ctx context.Context, args ...string) error {
+	_, err := t.execute(ctx, command{args: args, timeout: t.Timeout})
+	return err
+}
+
+// captureCommand menjalankan perintah tofu dan mengembalikan stdout-nya alih-alih mencetaknya.
+func (t *OpenTofuEngine) captureCommand(ctx context.Context, args ...string) ([]byte, error) {
+	return t.execute(ctx, command{args: args, capture: true, timeout: t.Timeout})
+}
+
+// applyPlan menjalankan tofu apply tfplan dengan ApplyTimeout. Sebelum percobaan ulang,
+// tfplan dibuat ulang dengan planArgs.
+func (t *OpenTofuEngine) applyPlan(ctx context.Context, planArgs []string) error {
+	_, err := t.execute(ctx, command{
+		args:    t.lockArgs("apply", "tfplan"),
+		timeout: t.ApplyTimeout,
+		replan:  t.lockArgs(planArgs...),
 	})
+	return err
+}
 
-	fmt.Printf("\n==> Menjalankan: %s (di direktori: %s)\n", commandStr, t.WorkDir)
+// execute menjalankan perintah tofu, mencoba ulang kegagalan sementara hingga MaxRetries kali.
+func (t *OpenTofuEngine) execute(ctx context.Context, c command) ([]byte, error) {
+	commandStr := fmt.Sprintf("tofu %s", strings.Join(c.args, " "))
+
+	for attempt := 0; ; attempt++ {
+		output, stderr, err := t.runOnce(ctx, c, commandStr)
+		if err == nil {
+			logger.Info("OpenTofu command completed successfully", logger.Fields{
+				"command": commandStr,
+			})
+			return output, nil
+		}
 
-	if err := cmd.Run(); err != nil {
 		logger.LogError(err, "OpenTofu command execution", logger.Fields{
 			"command":  commandStr,
 			"work_dir": t.WorkDir,
+			"attempt":  attempt + 1,
 		})
-		return &errors.ExecutionError{
-			Command:  commandStr,
-			Output:   err.Error(),
-			ExitCode: cmd.ProcessState.ExitCode(),
+
+		if ctx.Err() != nil || attempt >= t.MaxRetries || !isTransient(stderr) {
+			return nil, err
 		}
+
+		delay := retryDelay(attempt)
+		logger.Warn("Retrying OpenTofu command after transient failure", logger.Fields{
+			"command": commandStr,
+			"attempt": attempt + 2,
+			"delay":   delay.String(),
+		})
+		fmt.Printf("\n==> Kegagalan sementara, mencoba lagi (%d/%d) dalam %s\n", attempt+1, t.MaxRetries, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, &errors.ExecutionError{
+				Command:  commandStr,
+				Output:   fmt.Sprintf("cancelled: %v", ctx.Err()),
+				ExitCode: -1,
+			}
+		case <-time.After(delay):
+		}
+
+		if c.replan != nil {
+			fmt.Printf("\n==> Membuat ulang plan sebelum mencoba lagi\n")
+			if err := t.runCommand(ctx, c.replan...); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// runOnce menjalankan perintah tofu satu kali dan mengembalikan stdout (jika capture) serta stderr-nya.
+func (t *OpenTofuEngine) runOnce(ctx context.Context, c command, commandStr string) ([]byte, string, error) {
+	attemptCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(attemptCtx, "tofu", c.args...)
+	cmd.Dir = t.WorkDir
+	if len(t.Env) > 0 {
+		cmd.Env = append(os.Environ(), t.Env...)
+	}
+	cmd.WaitDelay = killGracePeriod
+	setProcessGroup(cmd)
+
+	// Output yang ditampilkan ke terminal disensor dari secret; stdout yang di-capture
+	// (JSON untuk diproses) dibiarkan apa adanya.
+	var stdout, stderr bytes.Buffer
+	consoleOut := logger.NewRedactingWriter(os.Stdout)
+	consoleErr := logger.NewRedactingWriter(os.Stderr)
+	if c.capture {
+		cmd.Stdout = &stdout
+	} else {
+		cmd.Stdout = consoleOut
 	}
+	cmd.Stderr = io.MultiWriter(consoleErr, &stderr)
 
-	logger.Info("OpenTofu command completed successfully", logger.Fields{
-		"command": commandStr,
+	logger.Info("Executing OpenTofu command", logger.Fields{
+		"command":  commandStr,
+		"work_dir": t.WorkDir,
+		"timeout":  c.timeout.String(),
 	})
 
-	return nil
+	if !c.capture {
+		fmt.Printf("\n==> Menjalankan: %s (di direktori: %s)\n", commandStr, t.WorkDir)
+	}
+
+	err := cmd.Run()
+	if attemptCtx.Err() != nil {
+		killProcessGroup(cmd)
+	}
+	consoleOut.Flush()
+	consoleErr.Flush()
+
+	switch {
+	case err == nil:
+		return stdout.Bytes(), stderr.String(), nil
+	case ctx.Err() != nil:
+		return nil, stderr.String(), &errors.ExecutionError{
+			Command:  commandStr,
+			Output:   fmt.Sprintf("cancelled: %v", ctx.Err()),
+			ExitCode: -1,
+		}
+	case attemptCtx.Err() == context.DeadlineExceeded:
+		return nil, stderr.String(), &errors.ExecutionError{
+			Command:  commandStr,
+			Output:   fmt.Sprintf("timed out after %s", c.timeout),
+			ExitCode: -1,
+		}
+	default:
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+		return nil, stderr.String(), &errors.ExecutionError{
+			Command:  commandStr,
+			Output:   logger.Redact(err.Error()),
+			ExitCode: exitCode,
+		}
+	}
 }
 
-func (t *OpenTofuEngine) Init() error {
+func (t *OpenTofuEngine) Init(ctx context.Context) error {
 	logger.Info("Initializing OpenTofu workspace", logger.Fields{
 		"work_dir": t.WorkDir,
 	})
 
-	return t.runCommand("init", "-upgrade")
+	return t.runCommand(ctx, "init", "-upgrade")
 }
 
-func (t *OpenTofuEngine) Plan() error {
+func (t *OpenTofuEngine) Plan(ctx context.Context) error {
 	logger.Info("Creating OpenTofu plan", logger.Fields{
 		"work_dir": t.WorkDir,
 	})
 
-	return t.runCommand("plan", "-out=tfplan")
+	return t.runCommand(ctx, t.lockArgs(planArgs...)...)
+}
+
+// ShowPlan mengembalikan representasi JSON dari plan terakhir (tofu show -json tfplan).
+func (t *OpenTofuEngine) ShowPlan(ctx context.Context) ([]byte, error) {
+	logger.Info("Reading OpenTofu plan", logger.Fields{
+		"work_dir": t.WorkDir,
+	})
+
+	return t.captureCommand(ctx, "show", "-json", "tfplan")
 }
 
-func (t *OpenTofuEngine) Apply() error {
+func (t *OpenTofuEngine) Apply(ctx context.Context) error {
 	logger.Info("Applying OpenTofu plan", logger.Fields{
 		"work_dir": t.WorkDir,
 	})
 
-	return t.runCommand("apply", "tfplan")
+	return t.applyPlan(ctx, planArgs)
 }
 
-func (t *OpenTofuEngine) PlanDestroy() error {
+func (t *OpenTofuEngine) PlanDestroy(ctx context.Context) error {
 	logger.Info("Creating OpenTofu destroy plan", logger.Fields{
 		"work_dir": t.WorkDir,
 	})
 
-	return t.runCommand("plan", "-destroy", "-out=tfplan")
+	return t.runCommand(ctx, t.lockArgs(destroyPlanArgs...)...)
 }
 
-func (t *OpenTofuEngine) Destroy() error {
+func (t *OpenTofuEngine) Destroy(ctx context.Context) error {
 	logger.Info("Destroying infrastructure", logger.Fields{
 		"work_dir": t.WorkDir,
 	})
 
-	return t.runCommand("apply", "tfplan")
+	return t.applyPlan(ctx, destroyPlanArgs)
 }
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{"state lock", "Error: Error acquiring the state lock\n\nLock Info:", true},
+		{"aws throttling", "api error Throttling: Rate exceeded", true},
+		{"http 429", "googleapi: Error 429: Too Many Requests", true},
+		{"invalid config", "Error: Unsupported argument", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.stderr); got != tt.want {
+				t.Errorf("isTransient() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for attempt, expected := range want {
+		if got := retryDelay(attempt); got != expected {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempt, got, expected)
+		}
+	}
+	if got := retryDelay(100); got != retryMaxDelay {
+		t.Errorf("retryDelay(100) = %s, want %s", got, retryMaxDelay)
+	}
+}
+
+// fakeTofu mencatat argumen setiap pemanggilan ke calls.log; apply pertama gagal dengan state
+// lock yang sedang dipakai dan setiap apply berjalan lebih lama dari Timeout.
+const fakeTofu = `#!/bin/sh
+echo "$*" >> calls.log
+if [ "$1" = "apply" ]; then
+  sleep 0.3
+  if [ ! -f applied ]; then
+    touch applied
+    echo "Error: Error acquiring the state lock" >&2
+    exit 1
+  fi
+fi
+`
+
+func TestApplyReplansBeforeRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tofu is a shell script")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "tofu"), []byte(fakeTofu), 0755); err != nil {
+		t.Fatalf("Failed to write fake tofu: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = 0
+
+	workDir := t.TempDir()
+	tofu := &OpenTofuEngine{WorkDir: workDir, Timeout: 100 * time.Millisecond, MaxRetries: 1}
+	if err := tofu.Destroy(context.Background()); err != nil {
+		t.Fatalf("Destroy() error = %v", err)
+	}
+
+	calls, err := os.ReadFile(filepath.Join(workDir, "calls.log"))
+	if err != nil {
+		t.Fatalf("Failed to read calls: %v", err)
+	}
+	want := "apply tfplan\nplan -destroy -out=tfplan\napply tfplan\n"
+	if string(calls) != want {
+		t.Errorf("tofu calls = %q, want %q", calls, want)
+	}
+
+	tofu.ApplyTimeout = 100 * time.Millisecond
+	err = tofu.Apply(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("Apply() with ApplyTimeout error = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup menjalankan tofu di process group sendiri sehingga provider plugin
+// yang di-spawn ikut dihentikan saat context dibatalkan.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// SIGTERM memberi tofu kesempatan melepas state lock sebelum keluar.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
+
+// killProcessGroup menghentikan paksa sisa proses di process group tofu.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package engine
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup tidak melakukan apa-apa di Windows; exec.CommandContext
+// sudah menghentikan proses tofu saat context dibatalkan.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup menghentikan paksa proses tofu.
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
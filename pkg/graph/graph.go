@@ -2,6 +2,7 @@ package graph
 
 import (
 	"bold/pkg/parser"
+	"bold/pkg/provider"
 	"fmt"
 	"strings"
 )
@@ -11,6 +12,7 @@ type DependencyNode struct {
 	Type      string
 	Name      string
 	Provider  string
+	Cloud     string
 	DependsOn []string
 }
 
@@ -32,6 +34,7 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 			Type:     "network",
 			Name:     network.Name,
 			Provider: network.Provider,
+			Cloud:    cloudName(service, network.Provider),
 		}
 		graph.Nodes = append(graph.Nodes, node)
 
@@ -42,6 +45,7 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 				Type:      "subnet",
 				Name:      subnet.Name,
 				Provider:  network.Provider,
+				Cloud:     cloudName(service, network.Provider),
 				DependsOn: []string{nodeID},
 			}
 			graph.Nodes = append(graph.Nodes, subnetNode)
@@ -49,6 +53,24 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 		}
 	}
 
+	for _, peering := range service.Spec.Infrastructure.Peerings {
+		peeringID := fmt.Sprintf("%s_%s", peering.Provider, peering.Name)
+		requesterID := fmt.Sprintf("%s_%s", peering.Provider, peering.VPCRequester)
+		accepterID := fmt.Sprintf("%s_%s", peering.Provider, peering.VPCAccepter)
+
+		node := DependencyNode{
+			ID:        peeringID,
+			Type:      "peering",
+			Name:      peering.Name,
+			Provider:  peering.Provider,
+			Cloud:     cloudName(service, peering.Provider),
+			DependsOn: []string{requesterID, accepterID},
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		graph.Edges[requesterID] = append(graph.Edges[requesterID], peeringID)
+		graph.Edges[accepterID] = append(graph.Edges[accepterID], peeringID)
+	}
+
 	for _, sg := range service.Spec.Infrastructure.SecurityGroups {
 		sgID := fmt.Sprintf("%s_%s", sg.Provider, sg.Name)
 		vpcID := fmt.Sprintf("%s_%s", sg.Provider, sg.VPC)
@@ -58,6 +80,7 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 			Type:      "security_group",
 			Name:      sg.Name,
 			Provider:  sg.Provider,
+			Cloud:     cloudName(service, sg.Provider),
 			DependsOn: []string{vpcID},
 		}
 		graph.Nodes = append(graph.Nodes, node)
@@ -66,17 +89,29 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 
 	for _, cluster := range service.Spec.Infrastructure.KubernetesClusters {
 		clusterID := fmt.Sprintf("%s_%s", cluster.Provider, cluster.Name)
-		vpcID := fmt.Sprintf("%s_%s", cluster.Provider, cluster.VPC)
+
+		// Clusters run in the subnets selected by their spec; without subnets they depend on the VPC.
+		var dependencies []string
+		for _, subnet := range provider.ClusterSubnets(service, cluster) {
+			dependencies = append(dependencies, fmt.Sprintf("%s_%s", cluster.Provider, subnet))
+		}
+		if len(dependencies) == 0 {
+			dependencies = []string{fmt.Sprintf("%s_%s", cluster.Provider, cluster.VPC)}
+		}
 
 		node := DependencyNode{
 			ID:        clusterID,
 			Type:      "kubernetes",
 			Name:      cluster.Name,
 			Provider:  cluster.Provider,
-			DependsOn: []string{vpcID},
+			Cloud:     cloudName(service, cluster.Provider),
+			DependsOn: dependencies,
 		}
 		graph.Nodes = append(graph.Nodes, node)
-		graph.Edges[vpcID] = append(graph.Edges[vpcID], clusterID)
+
+		for _, dep := range dependencies {
+			graph.Edges[dep] = append(graph.Edges[dep], clusterID)
+		}
 	}
 
 	for _, compute := range service.Spec.Infrastructure.Computes {
@@ -95,6 +130,7 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 			Type:      "compute",
 			Name:      compute.Name,
 			Provider:  compute.Provider,
+			Cloud:     cloudName(service, compute.Provider),
 			DependsOn: dependencies,
 		}
 		graph.Nodes = append(graph.Nodes, node)
@@ -107,6 +143,14 @@ func GenerateDependencyGraph(service *parser.Service) *DependencyGraph {
 	return graph
 }
 
+// cloudName returns the display name of the cloud behind a manifest provider reference.
+func cloudName(service *parser.Service, providerName string) string {
+	if _, backend, ok := provider.Resolve(service, providerName); ok {
+		return backend.Info().DisplayName
+	}
+	return ""
+}
+
 func GenerateMermaidDiagram(graph *DependencyGraph) string {
 	var mermaid strings.Builder
 	mermaid.WriteString("graph TD\n")
@@ -118,6 +162,8 @@ func GenerateMermaidDiagram(graph *DependencyGraph) string {
 			shape = "{{" + node.Name + "}}"
 		case "subnet":
 			shape = "[" + node.Name + "]"
+		case "peering":
+			shape = "((" + node.Name + "))"
 		case "security_group":
 			shape = "(" + node.Name + ")"
 		case "kubernetes":
@@ -154,6 +200,8 @@ func GenerateDotGraph(graph *DependencyGraph) string {
 			color = "lightblue"
 		case "subnet":
 			color = "lightgreen"
+		case "peering":
+			color = "lightcyan"
 		case "security_group":
 			color = "lightyellow"
 		case "kubernetes":
@@ -189,13 +237,17 @@ func PrintDependencyTree(graph *DependencyGraph) string {
 		nodesByType[node.Type] = append(nodesByType[node.Type], node)
 	}
 
-	types := []string{"network", "subnet", "security_group", "kubernetes", "compute"}
+	types := []string{"network", "subnet", "peering", "security_group", "kubernetes", "compute"}
 
 	for _, nodeType := range types {
 		if nodes, exists := nodesByType[nodeType]; exists {
 			tree.WriteString(fmt.Sprintf("%s:\n", strings.Title(nodeType)))
 			for _, node := range nodes {
-				tree.WriteString(fmt.Sprintf("  - %s (%s)\n", node.Name, node.Provider))
+				if node.Cloud != "" {
+					tree.WriteString(fmt.Sprintf("  - %s (%s, %s)\n", node.Name, node.Provider, node.Cloud))
+				} else {
+					tree.WriteString(fmt.Sprintf("  - %s (%s)\n", node.Name, node.Provider))
+				}
 				if len(node.DependsOn) > 0 {
 					tree.WriteString("    Depends on: ")
 					deps := make([]string, len(node.DependsOn))
@@ -0,0 +1,212 @@
+// Package ipplan reports how each network's address space is divided between its subnets.
+package ipplan
+
+import (
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"bold/pkg/parser"
+)
+
+// Report is the IP plan for every network in a manifest.
+type Report struct {
+	Networks []NetworkPlan
+}
+
+// NetworkPlan describes the allocated and free address space of one network.
+type NetworkPlan struct {
+	Name      string
+	Provider  string
+	CIDR      string
+	Total     uint64
+	Allocated uint64
+	Subnets   []SubnetPlan
+	Free      []string
+}
+
+// SubnetPlan is a subnet allocated from a network.
+type SubnetPlan struct {
+	Name string
+	CIDR string
+	Size uint64
+}
+
+// BuildIPPlan computes the IP plan for the networks of a service. Only IPv4 networks are
+// planned; networks whose CIDR does not parse are skipped since validation rejects them.
+func BuildIPPlan(service *parser.Service) *Report {
+	report := &Report{}
+
+	for _, network := range service.Spec.Infrastructure.Networks {
+		prefix, err := netip.ParsePrefix(network.CIDR)
+		if err != nil || !prefix.Addr().Is4() {
+			continue
+		}
+		prefix = prefix.Masked()
+
+		plan := NetworkPlan{
+			Name:     network.Name,
+			Provider: network.Provider,
+			CIDR:     prefix.String(),
+			Total:    prefixSize(prefix),
+		}
+
+		var used []addressRange
+		for _, subnet := range network.Subnets {
+			subnetPrefix, err := netip.ParsePrefix(subnet.CIDR)
+			if err != nil || !subnetPrefix.Addr().Is4() {
+				continue
+			}
+			subnetPrefix = subnetPrefix.Masked()
+
+			plan.Subnets = append(plan.Subnets, SubnetPlan{
+				Name: subnet.Name,
+				CIDR: subnetPrefix.String(),
+				Size: prefixSize(subnetPrefix),
+			})
+
+			if subnetPrefix.Bits() >= prefix.Bits() && prefix.Contains(subnetPrefix.Addr()) {
+				used = append(used, rangeOf(subnetPrefix))
+			}
+		}
+
+		merged := mergeRanges(used)
+		for _, r := range merged {
+			plan.Allocated += r.end - r.start + 1
+		}
+		for _, gap := range gaps(rangeOf(prefix), merged) {
+			plan.Free = append(plan.Free, rangeToCIDRs(gap)...)
+		}
+
+		report.Networks = append(report.Networks, plan)
+	}
+
+	return report
+}
+
+// FormatIPPlan renders the IP plan as text.
+func FormatIPPlan(report *Report) string {
+	var output strings.Builder
+
+	output.WriteString("🌐 IP Plan\n")
+	output.WriteString("==========\n\n")
+
+	if len(report.Networks) == 0 {
+		output.WriteString("No IPv4 networks defined.\n")
+		return output.String()
+	}
+
+	for _, network := range report.Networks {
+		usage := 0.0
+		if network.Total > 0 {
+			usage = float64(network.Allocated) / float64(network.Total) * 100
+		}
+
+		output.WriteString(fmt.Sprintf("%s (%s) %s\n", network.Name, network.Provider, network.CIDR))
+		output.WriteString(fmt.Sprintf("  Allocated: %d of %d addresses (%.1f%%)\n", network.Allocated, network.Total, usage))
+
+		for _, subnet := range network.Subnets {
+			output.WriteString(fmt.Sprintf("  ├── %-20s %-18s %d addresses\n", subnet.Name, subnet.CIDR, subnet.Size))
+		}
+
+		if len(network.Free) == 0 {
+			output.WriteString("  Free: none\n")
+		} else {
+			output.WriteString(fmt.Sprintf("  Free: %s\n", strings.Join(network.Free, ", ")))
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+// addressRange is an inclusive range of IPv4 addresses.
+type addressRange struct {
+	start uint64
+	end   uint64
+}
+
+func rangeOf(prefix netip.Prefix) addressRange {
+	start := uint64(addrToUint32(prefix.Addr()))
+	return addressRange{start: start, end: start + prefixSize(prefix) - 1}
+}
+
+func prefixSize(prefix netip.Prefix) uint64 {
+	return uint64(1) << (32 - prefix.Bits())
+}
+
+func addrToUint32(addr netip.Addr) uint32 {
+	b := addr.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func uint32ToAddr(v uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// mergeRanges sorts ranges and joins those that overlap or touch.
+func mergeRanges(ranges []addressRange) []addressRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := append([]addressRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+
+	merged := []addressRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end+1 {
+			if r.end > last.end {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// gaps returns the parts of network not covered by the merged, sorted used ranges.
+func gaps(network addressRange, used []addressRange) []addressRange {
+	var free []addressRange
+	next := network.start
+
+	for _, r := range used {
+		if r.start > next {
+			free = append(free, addressRange{start: next, end: r.start - 1})
+		}
+		if r.end+1 > next {
+			next = r.end + 1
+		}
+	}
+	if next <= network.end {
+		free = append(free, addressRange{start: next, end: network.end})
+	}
+	return free
+}
+
+// rangeToCIDRs splits a range into the fewest aligned CIDR blocks that cover it exactly.
+func rangeToCIDRs(r addressRange) []string {
+	var cidrs []string
+
+	for start := r.start; start <= r.end; {
+		// The largest block allowed by the alignment of start...
+		size := uint64(1) << 32
+		if start != 0 {
+			size = uint64(1) << bits.TrailingZeros64(start)
+		}
+		// ...shrunk until it fits in what is left of the range.
+		for size > r.end-start+1 {
+			size >>= 1
+		}
+
+		prefixLen := 32 - (bits.Len64(size) - 1)
+		cidrs = append(cidrs, netip.PrefixFrom(uint32ToAddr(uint32(start)), prefixLen).String())
+		start += size
+	}
+
+	return cidrs
+}
@@ -0,0 +1,43 @@
+package ipplan
+
+import (
+	"reflect"
+	"testing"
+
+	"bold/pkg/parser"
+)
+
+func TestBuildIPPlan(t *testing.T) {
+	service := &parser.Service{
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{
+						Name:     "vpc-main",
+						Provider: "aws_local",
+						CIDR:     "10.0.0.0/24",
+						Subnets: []parser.Subnet{
+							{Name: "subnet-a", CIDR: "10.0.0.0/26"},
+							{Name: "subnet-b", CIDR: "10.0.0.128/27"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	report := BuildIPPlan(service)
+	if len(report.Networks) != 1 {
+		t.Fatalf("BuildIPPlan() returned %d networks, want 1", len(report.Networks))
+	}
+
+	network := report.Networks[0]
+	if network.Total != 256 || network.Allocated != 96 {
+		t.Errorf("Total/Allocated = %d/%d, want 256/96", network.Total, network.Allocated)
+	}
+
+	wantFree := []string{"10.0.0.64/26", "10.0.0.160/27", "10.0.0.192/26"}
+	if !reflect.DeepEqual(network.Free, wantFree) {
+		t.Errorf("Free = %v, want %v", network.Free, wantFree)
+	}
+}
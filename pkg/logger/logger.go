@@ -16,6 +16,7 @@ type Fields map[string]interface{}
 // Init initializes the logger with configuration
 func Init(level, format, output string) error {
 	log = logrus.New()
+	log.AddHook(redactHook{})
 
 	// Set log level
 	logLevel, err := logrus.ParseLevel(level)
@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces secrets in log entries and OpenTofu output.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are the field name patterns whose values are always redacted. They are
+// regular expressions matched case-insensitively against any part of the name.
+var DefaultRedactKeys = []string{
+	"password", "passwd", "secret", "token", "private_key", "public_key",
+	"access_key", "api_key", "credential",
+}
+
+// secretShapes match values that look like secrets whatever field or line they appear in.
+var secretShapes = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\b(?:ssh-(?:rsa|ed25519|dss)|ecdsa-sha2-nistp\d+) AAAA[0-9A-Za-z+/]+=*`),
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+}
+
+// urlCredentials matches the password of credentials embedded in a URL.
+var urlCredentials = regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`)
+
+// minSecretValueLength keeps very short secret values from redacting ordinary text.
+const minSecretValueLength = 4
+
+// redactor holds the key patterns and the known secret values.
+type redactor struct {
+	mu sync.RWMutex
+	// keys matches field names to redact.
+	keys *regexp.Regexp
+	// assignments matches key=value and key: value pairs whose key matches a pattern.
+	assignments *regexp.Regexp
+	values      []string
+}
+
+var redaction = mustRedactor(DefaultRedactKeys)
+
+func mustRedactor(patterns []string) *redactor {
+	r := &redactor{}
+	if err := r.setKeys(patterns); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *redactor) setKeys(patterns []string) error {
+	alternatives := strings.Join(patterns, "|")
+	keys, err := regexp.Compile(`(?i)(?:` + alternatives + `)`)
+	if err != nil {
+		return fmt.Errorf("invalid redaction key pattern: %w", err)
+	}
+	assignments := regexp.MustCompile(`(?i)("?[\w.-]*(?:` + alternatives + `)[\w.-]*"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,;]+)`)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = keys
+	r.assignments = assignments
+	return nil
+}
+
+// SetRedactKeys adds field name patterns to DefaultRedactKeys. Patterns are regular
+// expressions matched case-insensitively; blank patterns are skipped.
+func SetRedactKeys(patterns []string) error {
+	keys := append([]string{}, DefaultRedactKeys...)
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			keys = append(keys, pattern)
+		}
+	}
+	return redaction.setKeys(keys)
+}
+
+// AddSecretValue makes Redact replace a known secret, such as a value resolved from a
+// secret reference, wherever it appears. Values shorter than 4 characters are ignored.
+func AddSecretValue(value string) {
+	if len(value) < minSecretValueLength {
+		return
+	}
+
+	redaction.mu.Lock()
+	defer redaction.mu.Unlock()
+	for _, known := range redaction.values {
+		if known == value {
+			return
+		}
+	}
+	redaction.values = append(redaction.values, value)
+}
+
+// Redact removes secrets from text: known secret values, the values of key=value pairs whose
+// key matches a redaction pattern, credentials in URLs and strings shaped like keys or tokens.
+func Redact(text string) string {
+	redaction.mu.RLock()
+	defer redaction.mu.RUnlock()
+
+	for _, value := range redaction.values {
+		text = strings.ReplaceAll(text, value, Redacted)
+	}
+	for _, shape := range secretShapes {
+		text = shape.ReplaceAllString(text, Redacted)
+	}
+	text = urlCredentials.ReplaceAllString(text, "${1}"+Redacted+"@")
+	return redaction.assignments.ReplaceAllStringFunc(text, func(match string) string {
+		groups := redaction.assignments.FindStringSubmatch(match)
+		if groups[2] == Redacted {
+			return match
+		}
+		return groups[1] + Redacted
+	})
+}
+
+// RedactFields returns a copy of fields with the values of secret-named fields replaced and
+// secrets removed from the other string values.
+func RedactFields(fields Fields) Fields {
+	redacted := make(Fields, len(fields))
+	for key, value := range fields {
+		redacted[key] = redactField(key, value)
+	}
+	return redacted
+}
+
+func redactField(key string, value interface{}) interface{} {
+	redaction.mu.RLock()
+	secretKey := redaction.keys.MatchString(key)
+	redaction.mu.RUnlock()
+	if secretKey {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case error:
+		return Redact(v.Error())
+	default:
+		return value
+	}
+}
+
+// redactHook applies redaction to every entry before it is formatted.
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		entry.Data[key] = redactField(key, value)
+	}
+	return nil
+}
+
+// RedactingWriter redacts secrets from a stream line by line before passing it on. Private
+// key blocks that span several lines are replaced as a whole. Call Flush once the stream
+// ends to write a final line without a newline.
+type RedactingWriter struct {
+	mu           sync.Mutex
+	out          io.Writer
+	pending      []byte
+	inPrivateKey bool
+}
+
+// NewRedactingWriter returns a RedactingWriter that writes to out.
+func NewRedactingWriter(out io.Writer) *RedactingWriter {
+	return &RedactingWriter{out: out}
+}
+
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			break
+		}
+		line := string(w.pending[:end+1])
+		w.pending = w.pending[end+1:]
+		if err := w.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the buffered partial line, if any.
+func (w *RedactingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	line := string(w.pending)
+	w.pending = nil
+	return w.writeLine(line)
+}
+
+func (w *RedactingWriter) writeLine(line string) error {
+	switch {
+	case w.inPrivateKey:
+		if strings.Contains(line, "-----END ") {
+			w.inPrivateKey = false
+		}
+		return nil
+	case strings.Contains(line, "PRIVATE KEY-----") && strings.Contains(line, "-----BEGIN ") && !strings.Contains(line, "-----END "):
+		w.inPrivateKey = true
+		_, err := io.WriteString(w.out, Redacted+"\n")
+		return err
+	default:
+		_, err := io.WriteString(w.out, Redact(line))
+		return err
+	}
+}
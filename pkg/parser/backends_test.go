@@ -0,0 +1,6 @@
+package parser_test
+
+// Validation only accepts provider types with a registered backend. The backends import
+// this package, so they are registered from an external test file; it is compiled into
+// the same test binary, which makes the built-in types visible to the internal tests too.
+import _ "bold/pkg/provider/builtin"
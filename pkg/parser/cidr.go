@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// validateAddressSpace checks how CIDR blocks relate to each other: every subnet must lie
+// inside its network, sibling subnets must not overlap, and networks joined by a peering
+// must not share address space. Blocks that do not parse are skipped here because
+// validateNetwork and validateSubnet already report them.
+func validateAddressSpace(service *Service, result *ValidationResult) {
+	infra := service.Spec.Infrastructure
+	path := "spec.infrastructure"
+	networks := make(map[string]netip.Prefix)
+
+	for i, network := range infra.Networks {
+		networkPath := fmt.Sprintf("%s.networks[%d]", path, i)
+		networkPrefix, networkOK := parsePrefix(network.CIDR)
+		if networkOK {
+			if _, exists := networks[network.Name]; !exists {
+				networks[network.Name] = networkPrefix
+			}
+		}
+
+		var siblings []Subnet
+		var siblingPrefixes []netip.Prefix
+		for j, subnet := range network.Subnets {
+			subnetPath := fmt.Sprintf("%s.subnets[%d].cidr", networkPath, j)
+			subnetPrefix, ok := parsePrefix(subnet.CIDR)
+			if !ok {
+				continue
+			}
+
+			if networkOK && !prefixContains(networkPrefix, subnetPrefix) {
+				result.AddError(subnetPath, fmt.Sprintf("subnet CIDR %s is outside network %s (%s)", subnet.CIDR, network.Name, network.CIDR))
+			}
+
+			for k, sibling := range siblings {
+				if siblingPrefixes[k].Overlaps(subnetPrefix) {
+					result.AddError(subnetPath, fmt.Sprintf("subnet CIDR %s overlaps subnet %s (%s)", subnet.CIDR, sibling.Name, sibling.CIDR))
+				}
+			}
+
+			siblings = append(siblings, subnet)
+			siblingPrefixes = append(siblingPrefixes, subnetPrefix)
+		}
+	}
+
+	for i, peering := range infra.Peerings {
+		requester, requesterOK := networks[peering.VPCRequester]
+		accepter, accepterOK := networks[peering.VPCAccepter]
+		if !requesterOK || !accepterOK || peering.VPCRequester == peering.VPCAccepter {
+			continue
+		}
+
+		if requester.Overlaps(accepter) {
+			result.AddError(fmt.Sprintf("%s.peerings[%d].vpc_accepter", path, i),
+				fmt.Sprintf("VPC %s (%s) overlaps peered VPC %s (%s)", peering.VPCAccepter, accepter, peering.VPCRequester, requester))
+		}
+	}
+}
+
+// parsePrefix parses a CIDR block and clears any host bits, so "10.0.1.5/24" is treated as 10.0.1.0/24.
+func parsePrefix(cidr string) (netip.Prefix, bool) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix.Masked(), true
+}
+
+// prefixContains reports whether inner lies entirely within outer.
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
@@ -0,0 +1,298 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ComponentKind is the kind of manifest documents that can only be imported.
+const ComponentKind = "Component"
+
+// Import instantiates a component in a manifest. The names of the objects the component
+// declares are prefixed with Name, so that one component can be imported several times.
+type Import struct {
+	Name string `yaml:"name"`
+	// Source is a component file relative to the importing manifest, or the name of a
+	// component in the library directory.
+	Source     string                 `yaml:"source"`
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
+}
+
+var (
+	// componentLists are the infrastructure lists a component can add objects to.
+	componentLists = []string{"networks", "peerings", "security_groups", "kubernetes_clusters", "computes"}
+	// componentKeys are the top-level keys of a component document.
+	componentKeys = []string{"apiVersion", "kind", "metadata", "parameters", "imports", "spec"}
+	// referenceKeys hold the names of other objects; references to objects of the same
+	// component are prefixed along with the objects.
+	referenceKeys = []string{"vpc", "subnet", "subnets", "security_group", "vpc_requester", "vpc_accepter", "source_vpc"}
+)
+
+// resolveImports instantiates the components imported by a manifest or component and adds
+// their objects to its spec.infrastructure. stack holds the files being imported, outermost
+// first, to detect import cycles.
+func resolveImports(root *yaml.Node, manifestPath string, opts ParseOptions, stack []string) error {
+	importsNode := mappingValue(root, "imports")
+	if importsNode == nil {
+		return nil
+	}
+	var imports []Import
+	if err := importsNode.Decode(&imports); err != nil {
+		return fmt.Errorf("invalid imports: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i, imp := range imports {
+		if !simpleName.MatchString(imp.Name) {
+			return fmt.Errorf("imports[%d].name: import name must contain only alphanumeric characters, hyphens, and underscores", i)
+		}
+		if seen[imp.Name] {
+			return fmt.Errorf("imports[%d].name: duplicate import name %s", i, imp.Name)
+		}
+		seen[imp.Name] = true
+	}
+
+	for i, imp := range imports {
+		field := fmt.Sprintf("imports[%d]", i)
+		source, err := componentPath(manifestPath, imp.Source, opts.LibraryDir)
+		if err != nil {
+			return fmt.Errorf("%s.source: %w", field, err)
+		}
+		infra, err := instantiateComponent(imp, source, opts, stack)
+		if err != nil {
+			return fmt.Errorf("%s (%s, %s): %w", field, imp.Name, imp.Source, err)
+		}
+
+		for _, key := range componentLists {
+			items := mappingValue(infra, key)
+			if items == nil || len(items.Content) == 0 {
+				continue
+			}
+			list := childNode(root, yaml.SequenceNode, "spec", "infrastructure", key)
+			list.Content = append(list.Content, items.Content...)
+		}
+	}
+	return nil
+}
+
+// componentPath returns the absolute path of an import source. Sources that look like paths
+// are relative to the importing file; other sources name a component in the library.
+func componentPath(importer, source, library string) (string, error) {
+	if source == "" {
+		return "", fmt.Errorf("import source is required")
+	}
+
+	path := source
+	switch ext := filepath.Ext(source); {
+	case filepath.IsAbs(source):
+	case strings.HasPrefix(source, "./"), strings.HasPrefix(source, "../"), ext == ".yaml", ext == ".yml":
+		path = filepath.Join(filepath.Dir(importer), source)
+	default:
+		if library == "" {
+			return "", fmt.Errorf("component %s is not a path and no component library is configured", source)
+		}
+		library, err := ExpandHome(library)
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(library, source+".yaml")
+	}
+	return filepath.Abs(path)
+}
+
+// instantiateComponent reads a component, substitutes its parameters, resolves its own
+// imports and returns its spec.infrastructure with the object names prefixed.
+func instantiateComponent(imp Import, source string, opts ParseOptions, stack []string) (*yaml.Node, error) {
+	if i := slices.Index(stack, source); i >= 0 {
+		return nil, fmt.Errorf("import cycle: %s", strings.Join(append(slices.Clone(stack[i:]), source), " -> "))
+	}
+	stack = append(slices.Clone(stack), source)
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse component: %w", err)
+	}
+	root := documentRoot(&document)
+	if kind := mappingValue(root, "kind"); kind == nil || kind.Value != ComponentKind {
+		return nil, fmt.Errorf("%s is not a %s", source, ComponentKind)
+	}
+	if err := checkComponentKeys(root); err != nil {
+		return nil, err
+	}
+
+	result := &ValidationResult{}
+	r := &interpolator{
+		kind:     "parameter",
+		block:    "parameters",
+		ref:      parameterRef,
+		declared: declaredValues(&document, "parameters", result),
+		values:   map[string]interface{}{},
+		invalid:  map[string]bool{},
+		result:   result,
+		hint: func(name string) string {
+			return fmt.Sprintf("set it under parameters of import %s", imp.Name)
+		},
+	}
+	for name, parameter := range r.declared {
+		if parameter.Default != nil {
+			r.values[name] = parameter.Default
+		}
+	}
+	for _, name := range sortedKeys(imp.Parameters) {
+		if _, declared := r.declared[name]; !declared {
+			result.AddError("parameters."+name, fmt.Sprintf("undeclared parameter %s", name))
+			continue
+		}
+		r.values[name] = imp.Parameters[name]
+	}
+	r.checkValues()
+	r.interpolate(&document, "")
+	checkComponentVariables(&document, "", result)
+	if result.HasErrors() {
+		return nil, result
+	}
+
+	resolveUserDataFiles(root, filepath.Dir(source))
+
+	if err := resolveImports(root, source, opts, stack); err != nil {
+		return nil, err
+	}
+
+	infra := mappingValue(mappingValue(root, "spec"), "infrastructure")
+	if infra == nil {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	prefixNames(infra, imp.Name)
+	return infra, nil
+}
+
+// checkComponentKeys rejects component content that cannot be added to a service, such as
+// providers or a key pair.
+func checkComponentKeys(root *yaml.Node) error {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; !slices.Contains(componentKeys, key) {
+			return fmt.Errorf("%s: unsupported key in a component (supported: %s)", key, strings.Join(componentKeys, ", "))
+		}
+	}
+
+	spec := mappingValue(root, "spec")
+	if spec == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(spec.Content); i += 2 {
+		if key := spec.Content[i].Value; key != "infrastructure" {
+			return fmt.Errorf("spec.%s: components can only declare spec.infrastructure", key)
+		}
+	}
+
+	infra := mappingValue(spec, "infrastructure")
+	if infra == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(infra.Content); i += 2 {
+		if key := infra.Content[i].Value; !slices.Contains(componentLists, key) {
+			return fmt.Errorf("spec.infrastructure.%s: unsupported in a component (supported: %s)", key, strings.Join(componentLists, ", "))
+		}
+	}
+	return nil
+}
+
+// checkComponentVariables reports the variable references below node: a component only sees
+// its parameters, so a variable has to be passed in as one. Escaped references become literal
+// ${var.<name>} text, as in manifests.
+func checkComponentVariables(node *yaml.Node, path string, result *ValidationResult) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			checkComponentVariables(child, path, result)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkComponentVariables(node.Content[i+1], joinFieldPath(path, node.Content[i].Value), result)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			checkComponentVariables(child, fmt.Sprintf("%s[%d]", path, i), result)
+		}
+	case yaml.ScalarNode:
+		node.Value = variableRef.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name := variableRef.FindStringSubmatch(ref)[1]
+			result.AddError(path, fmt.Sprintf("variable %s cannot be used in a component (line %d, column %d); declare a parameter and set it to ${var.%s} in the import",
+				name, node.Line, node.Column, name))
+			return ref
+		})
+	}
+}
+
+// prefixNames prefixes the names of the objects declared in infra, and the references to
+// them, with prefix.
+func prefixNames(infra *yaml.Node, prefix string) {
+	declared := map[string]bool{}
+	var objects []*yaml.Node
+	for _, key := range componentLists {
+		items := mappingValue(infra, key)
+		if items == nil {
+			continue
+		}
+		for _, item := range items.Content {
+			objects = append(objects, item)
+			if key != "networks" {
+				continue
+			}
+			if subnets := mappingValue(item, "subnets"); subnets != nil {
+				objects = append(objects, subnets.Content...)
+			}
+		}
+	}
+	for _, object := range objects {
+		if name := mappingValue(object, "name"); name != nil && name.Kind == yaml.ScalarNode {
+			declared[name.Value] = true
+			name.Value = prefix + "-" + name.Value
+		}
+	}
+
+	prefixReferences(infra, prefix, declared)
+}
+
+// prefixReferences prefixes the values of reference keys below node that name one of the
+// declared objects.
+func prefixReferences(node *yaml.Node, prefix string, declared map[string]bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if !slices.Contains(referenceKeys, key) {
+				prefixReferences(value, prefix, declared)
+				continue
+			}
+
+			references := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				references = value.Content
+			}
+			for _, ref := range references {
+				if ref.Kind == yaml.ScalarNode && declared[ref.Value] {
+					ref.Value = prefix + "-" + ref.Value
+				} else {
+					prefixReferences(ref, prefix, declared)
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			prefixReferences(item, prefix, declared)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// overlayDir is the directory next to a base manifest that holds its environment overlays.
+const overlayDir = "overlays"
+
+// patchKey marks an item of a named list in an overlay: "$patch: delete" removes the base
+// item with the same name and "$patch: replace" replaces it instead of merging.
+const patchKey = "$patch"
+
+// simpleName matches environment and import names, which end up in paths and resource names.
+var simpleName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// OverlayPath returns the overlay of an environment for a base manifest,
+// overlays/<env>.yaml in the directory of the manifest.
+func OverlayPath(manifestPath, env string) string {
+	return filepath.Join(filepath.Dir(manifestPath), overlayDir, env+".yaml")
+}
+
+// envOverlay is the overlay of an environment. Mappings are merged key by key, a null value
+// removes a key, and lists whose items have a name are merged item by item on that name;
+// other lists and values are replaced.
+type envOverlay struct {
+	env  string
+	path string
+	// settings is the overlay without its spec.infrastructure. It is merged before variables
+	// and imports are resolved, so that it can change them.
+	settings *yaml.Node
+	// infrastructure holds only the spec.infrastructure of the overlay. It is merged once the
+	// components are imported, so that it can patch their objects by their prefixed names.
+	infrastructure *yaml.Node
+}
+
+// readOverlay reads the overlay of env for a base manifest.
+func readOverlay(manifestPath, env string) (*envOverlay, error) {
+	if !simpleName.MatchString(env) {
+		return nil, fmt.Errorf("invalid environment %q: use only alphanumeric characters, hyphens, and underscores", env)
+	}
+
+	path := OverlayPath(manifestPath, env)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no overlay for environment %s: %s does not exist", env, path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse overlay %s: %w", path, err)
+	}
+
+	o := &envOverlay{env: env, path: path, settings: documentRoot(&document)}
+	spec := mappingValue(o.settings, "spec")
+	if index := mappingIndex(spec, "infrastructure"); index >= 0 {
+		o.infrastructure = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		setMappingValue(childNode(o.infrastructure, yaml.MappingNode, "spec"), "infrastructure", spec.Content[index+1])
+		spec.Content = append(spec.Content[:index], spec.Content[index+2:]...)
+	}
+	return o, nil
+}
+
+// applySettings merges the overlay settings into a base manifest document and sets the
+// environment tag to env, so that resources are tagged with the environment they belong to.
+func (o *envOverlay) applySettings(document *yaml.Node) error {
+	if err := o.merge(document, o.settings); err != nil {
+		return err
+	}
+	setValue(documentRoot(document), o.env, "metadata", "tags", "environment")
+	return nil
+}
+
+// applyInfrastructure merges the overlay spec.infrastructure into a manifest document whose
+// components have been imported.
+func (o *envOverlay) applyInfrastructure(document *yaml.Node) error {
+	return o.merge(document, o.infrastructure)
+}
+
+func (o *envOverlay) merge(document, overlay *yaml.Node) error {
+	if overlay == nil {
+		return nil
+	}
+	if len(document.Content) == 0 {
+		document.Kind = yaml.DocumentNode
+		document.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+
+	result := &ValidationResult{}
+	mergeNode(document.Content[0], overlay, "", result)
+	if result.HasErrors() {
+		return fmt.Errorf("invalid overlay %s: %w", o.path, result)
+	}
+	return nil
+}
+
+// mergeNode merges overlay into base in place; path is the field path of base.
+func mergeNode(base, overlay *yaml.Node, path string, result *ValidationResult) {
+	switch {
+	case base.Kind == yaml.MappingNode && overlay.Kind == yaml.MappingNode:
+		mergeMapping(base, overlay, path, result)
+	case base.Kind == yaml.SequenceNode && overlay.Kind == yaml.SequenceNode && namedItems(overlay):
+		mergeNamedList(base, overlay, path, result)
+	default:
+		*base = *withoutPatchKey(overlay)
+	}
+}
+
+func mergeMapping(base, overlay *yaml.Node, path string, result *ValidationResult) {
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		if key.Value == patchKey {
+			continue
+		}
+		childPath := joinFieldPath(path, key.Value)
+
+		index := mappingIndex(base, key.Value)
+		switch {
+		case value.Tag == "!!null":
+			if index >= 0 {
+				base.Content = append(base.Content[:index], base.Content[index+2:]...)
+			}
+		case index >= 0:
+			mergeNode(base.Content[index+1], value, childPath, result)
+		default:
+			base.Content = append(base.Content, key, withoutPatchKey(value))
+		}
+	}
+}
+
+func mergeNamedList(base, overlay *yaml.Node, path string, result *ValidationResult) {
+	for _, item := range overlay.Content {
+		name := mappingValue(item, "name").Value
+		patch := mappingValue(item, patchKey)
+
+		index := -1
+		for i, baseItem := range base.Content {
+			if value := mappingValue(baseItem, "name"); value != nil && value.Value == name {
+				index = i
+				break
+			}
+		}
+		itemPath := fmt.Sprintf("%s[name=%s]", path, name)
+
+		switch {
+		case patch != nil && patch.Value != "delete" && patch.Value != "replace":
+			result.AddError(itemPath+"."+patchKey, fmt.Sprintf("unsupported patch %q (supported: delete, replace)", patch.Value))
+		case patch != nil && patch.Value == "delete":
+			if index < 0 {
+				result.AddError(itemPath, fmt.Sprintf("no item named %s to delete", name))
+				continue
+			}
+			base.Content = append(base.Content[:index], base.Content[index+1:]...)
+		case index < 0:
+			base.Content = append(base.Content, withoutPatchKey(item))
+		case patch != nil:
+			base.Content[index] = withoutPatchKey(item)
+		default:
+			mergeNode(base.Content[index], item, itemPath, result)
+		}
+	}
+}
+
+// namedItems reports whether every item of a list is a mapping with a name.
+func namedItems(list *yaml.Node) bool {
+	for _, item := range list.Content {
+		if value := mappingValue(item, "name"); value == nil || value.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return len(list.Content) > 0
+}
+
+// withoutPatchKey returns node without the $patch keys of the overlay, which must not reach
+// the merged manifest.
+func withoutPatchKey(node *yaml.Node) *yaml.Node {
+	switch node.Kind {
+	case yaml.MappingNode:
+		copied := *node
+		copied.Content = nil
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == patchKey {
+				continue
+			}
+			copied.Content = append(copied.Content, node.Content[i], withoutPatchKey(node.Content[i+1]))
+		}
+		return &copied
+	case yaml.SequenceNode:
+		copied := *node
+		copied.Content = make([]*yaml.Node, len(node.Content))
+		for i, item := range node.Content {
+			copied.Content[i] = withoutPatchKey(item)
+		}
+		return &copied
+	default:
+		return node
+	}
+}
+
+// setValue sets the value at a path of mapping keys, creating the mappings along the way.
+func setValue(node *yaml.Node, value string, keys ...string) {
+	parent := childNode(node, yaml.MappingNode, keys[:len(keys)-1]...)
+	if parent == nil {
+		return
+	}
+	setMappingValue(parent, keys[len(keys)-1], &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
+
+// childNode returns the node at a path of mapping keys, creating missing or null nodes along
+// the way; the last one is created with the given kind. It returns nil when the path runs
+// into a node that is not a mapping.
+func childNode(node *yaml.Node, kind yaml.Kind, keys ...string) *yaml.Node {
+	for i, key := range keys {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		child := mappingValue(node, key)
+		if child == nil || child.Tag == "!!null" {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if i == len(keys)-1 && kind == yaml.SequenceNode {
+				child = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			setMappingValue(node, key, child)
+		}
+		node = child
+	}
+	return node
+}
+
+// setMappingValue sets the value of a key in a mapping node.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	if index := mappingIndex(node, key); index >= 0 {
+		node.Content[index+1] = value
+		return
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// documentRoot returns the top-level node of a document, or nil for an empty document.
+func documentRoot(document *yaml.Node) *yaml.Node {
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil
+	}
+	return document.Content[0]
+}
+
+// mappingIndex returns the index of a key in a mapping node, or -1.
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of a key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if index := mappingIndex(node, key); index >= 0 {
+		return node.Content[index+1]
+	}
+	return nil
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
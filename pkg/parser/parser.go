@@ -1,19 +1,28 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"bold/pkg/config"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Service represents the Go structure of the service.yaml file
 type Service struct {
-	APIVersion string     `yaml:"apiVersion"`
-	Kind       string     `yaml:"kind"`
-	Metadata   Metadata   `yaml:"metadata"`
-	Providers  []Provider `yaml:"providers"`
-	Spec       Spec       `yaml:"spec"`
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   Metadata `yaml:"metadata"`
+	// Variables are the variables ${var.<name>} refers to; see ParseManifestWithOptions.
+	Variables map[string]Variable `yaml:"variables,omitempty"`
+	// Imports are the components whose objects were added to Spec.Infrastructure.
+	Imports   []Import   `yaml:"imports,omitempty"`
+	Providers []Provider `yaml:"providers"`
+	Spec      Spec       `yaml:"spec"`
 }
 
 type Metadata struct {
@@ -26,12 +35,19 @@ type Provider struct {
 	Name string                 `yaml:"name"`
 	Type string                 `yaml:"type"`
 	Spec map[string]interface{} `yaml:"spec"`
+	// TypedSpec is Spec decoded into the struct registered for Type; set by DecodeSpecs.
+	TypedSpec ProviderSpec `yaml:"-"`
 }
 
+// KeyPair is the SSH key pair installed on computes. With UseExisting the public key is read
+// from PublicKeyFile; otherwise Bolt generates an ed25519 key pair on the first compile and
+// reuses it afterwards.
 type KeyPair struct {
 	Name          string `yaml:"name"`
 	PublicKeyFile string `yaml:"public_key_file"`
-	UseExisting   bool   `yaml:"use_existing"`
+	// PrivateKeyFile is where a generated private key is stored; it defaults to the build directory.
+	PrivateKeyFile string `yaml:"private_key_file,omitempty"`
+	UseExisting    bool   `yaml:"use_existing"`
 }
 
 type Infrastructure struct {
@@ -44,9 +60,10 @@ type Infrastructure struct {
 }
 
 type Spec struct {
-	Provider       Provider       `yaml:"provider"`
-	KeyPair        KeyPair        `yaml:"key_pair"`
-	Infrastructure Infrastructure `yaml:"infrastructure"`
+	Provider       Provider            `yaml:"provider"`
+	KeyPair        KeyPair             `yaml:"key_pair"`
+	State          config.StateBackend `yaml:"state"`
+	Infrastructure Infrastructure      `yaml:"infrastructure"`
 }
 
 type Network struct {
@@ -90,6 +107,8 @@ type KubernetesCluster struct {
 	Provider string                 `yaml:"provider"`
 	VPC      string                 `yaml:"vpc"`
 	Spec     map[string]interface{} `yaml:"spec"`
+	// TypedSpec is Spec decoded into the struct registered for the provider type; set by DecodeSpecs.
+	TypedSpec ClusterSpec `yaml:"-"`
 }
 
 type Compute struct {
@@ -99,27 +118,50 @@ type Compute struct {
 	VPC           string                 `yaml:"vpc"`
 	Subnet        string                 `yaml:"subnet"`
 	SecurityGroup string                 `yaml:"security_group"`
+	Image         *Image                 `yaml:"image,omitempty"`
+	UserData      *UserData              `yaml:"user_data,omitempty"`
 	Storage       []Storage              `yaml:"storage"`
 	Spec          map[string]interface{} `yaml:"spec"`
+	// TypedSpec is Spec decoded into the struct registered for Type; set by DecodeSpecs.
+	TypedSpec ComputeSpec `yaml:"-"`
+}
+
+// Image selects the operating system of a compute. OS, Version and Arch are mapped to an
+// image of each cloud; ID names a cloud-specific image instead (an AMI ID, an Azure image
+// ID or publisher:offer:sku:version URN, or a GCP image).
+type Image struct {
+	OS      string `yaml:"os,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	Arch    string `yaml:"arch,omitempty"`
+	ID      string `yaml:"id,omitempty"`
 }
 
 type Storage struct {
-	Name      string `yaml:"name"`
-	Path      string `yaml:"path"`
-	Size      int    `yaml:"size"`
-	Type      string `yaml:"type"`
-	Encrypted bool   `yaml:"encrypted"`
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	Size int    `yaml:"size"`
+	// Type is a disk type of the cloud, checked against SpecTypes.StorageTypes.
+	Type string `yaml:"type"`
+	// Encrypted only applies to AWS. Azure managed disks and GCP persistent disks are always
+	// encrypted at rest with platform-managed keys, whatever its value.
+	Encrypted bool `yaml:"encrypted"`
 }
 
 // ParseManifest reads and parses the service manifest file
 func ParseManifest(path string) (*Service, error) {
-	data, err := os.ReadFile(path)
+	return ParseManifestWithOptions(path, ParseOptions{})
+}
+
+// ParseManifestWithOptions reads and parses the service manifest file, applying the overlay
+// of opts.Env and resolving its variables with the values in opts.
+func ParseManifestWithOptions(path string, opts ParseOptions) (*Service, error) {
+	document, err := loadManifest(path, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	var service Service
-	if err := yaml.Unmarshal(data, &service); err != nil {
+	if err := document.Decode(&service); err != nil {
 		return nil, err
 	}
 
@@ -130,3 +172,86 @@ func ParseManifest(path string) (*Service, error) {
 
 	return &service, nil
 }
+
+// EffectiveManifest returns the manifest ParseManifestWithOptions validates, with the
+// overlay applied, the variables resolved and the components instantiated, as YAML.
+func EffectiveManifest(path string, opts ParseOptions) ([]byte, error) {
+	document, err := loadManifest(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// loadManifest reads a manifest document, applies the overlay of opts.Env, resolves its
+// variables and instantiates the components it imports. The spec.infrastructure of the
+// overlay is merged last, so that it can patch the objects of components too.
+func loadManifest(path string, opts ParseOptions) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	overlay := &envOverlay{}
+	if opts.Env != "" {
+		if overlay, err = readOverlay(path, opts.Env); err != nil {
+			return nil, err
+		}
+		if err := overlay.applySettings(&document); err != nil {
+			return nil, err
+		}
+	}
+
+	root := documentRoot(&document)
+	if kind := mappingValue(root, "kind"); kind != nil && kind.Value == ComponentKind {
+		return nil, fmt.Errorf("%s is a %s; import it from a service manifest", path, ComponentKind)
+	}
+
+	if err := resolveVariables(&document, opts, overlay.infrastructure); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	resolveUserDataFiles(root, filepath.Dir(path))
+	resolveUserDataFiles(overlay.infrastructure, filepath.Dir(overlay.path))
+
+	if root != nil {
+		source, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := resolveImports(root, path, opts, []string{source}); err != nil {
+			return nil, fmt.Errorf("failed to import components: %w", err)
+		}
+	}
+
+	if err := overlay.applyInfrastructure(&document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// ExpandHome replaces a leading ~/ with the home directory of the user.
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory")
+	}
+	return strings.Replace(path, "~", homeDir, 1), nil
+}
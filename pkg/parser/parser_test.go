@@ -2,7 +2,13 @@ package parser
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"bold/pkg/config"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestParseManifest(t *testing.T) {
@@ -158,6 +164,105 @@ func TestValidateService(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "existing key pair without public key file",
+			service: &Service{
+				Metadata: Metadata{
+					Name:  "test-service",
+					Owner: "test-owner",
+				},
+				Providers: []Provider{
+					{
+						Name: "aws_test",
+						Type: "aws",
+					},
+				},
+				Spec: Spec{
+					KeyPair: KeyPair{
+						Name:        "bolt-key",
+						UseExisting: true,
+					},
+					Infrastructure: Infrastructure{},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown source VPC in security group rule",
+			service: &Service{
+				Metadata: Metadata{
+					Name:  "test-service",
+					Owner: "test-owner",
+				},
+				Providers: []Provider{
+					{
+						Name: "aws_test",
+						Type: "aws",
+					},
+				},
+				Spec: Spec{
+					Infrastructure: Infrastructure{
+						Networks: []Network{
+							{Name: "vpc-main", Provider: "aws_test", CIDR: "10.0.0.0/16"},
+						},
+						SecurityGroups: []SecurityGroup{
+							{
+								Name:     "app-sg",
+								Provider: "aws_test",
+								VPC:      "vpc-main",
+								Rules: []SecurityGroupRule{
+									{Type: "ingress", Protocol: "tcp", FromPort: 22, ToPort: 22, SourceVPC: "vpc-missing"},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported state backend",
+			service: &Service{
+				Metadata: Metadata{
+					Name:  "test-service",
+					Owner: "test-owner",
+				},
+				Providers: []Provider{
+					{
+						Name: "aws_test",
+						Type: "aws",
+					},
+				},
+				Spec: Spec{
+					State: config.StateBackend{
+						Backend: "consul",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "s3 state backend without bucket",
+			service: &Service{
+				Metadata: Metadata{
+					Name:  "test-service",
+					Owner: "test-owner",
+				},
+				Providers: []Provider{
+					{
+						Name: "aws_test",
+						Type: "aws",
+					},
+				},
+				Spec: Spec{
+					State: config.StateBackend{
+						Backend: "s3",
+						Config:  map[string]interface{}{"region": "us-east-1"},
+					},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -170,6 +275,209 @@ func TestValidateService(t *testing.T) {
 	}
 }
 
+func TestValidateReferences(t *testing.T) {
+	service := &Service{
+		Providers: []Provider{
+			{Name: "aws_test", Type: "aws"},
+			{Name: "aws_other", Type: "aws"},
+			{Name: "azure_test", Type: "azurerm"},
+		},
+		Spec: Spec{
+			Infrastructure: Infrastructure{
+				Networks: []Network{
+					{Name: "vpc-a", Provider: "aws_test", Subnets: []Subnet{{Name: "subnet-a"}}},
+					{Name: "vnet-a", Provider: "azure_test"},
+					{Name: "vpc-b", Provider: "aws_test", Subnets: []Subnet{{Name: "subnet-b"}}},
+					{Name: "vpc-a", Provider: "aws_test"},
+					{Name: "vpc-c", Provider: "aws_other"},
+				},
+				SecurityGroups: []SecurityGroup{
+					{Name: "sg-b", Provider: "aws_test", VPC: "vpc-b"},
+					{Name: "sg-x", Provider: "aws_test", VPC: "vpc-missing"},
+				},
+				Computes: []Compute{
+					{Name: "vm-1", Provider: "aws_test", VPC: "vpc-a", Subnet: "subnet-b", SecurityGroup: "sg-b"},
+					{Name: "vm-2", Provider: "aws_missing", VPC: "vpc-a", Subnet: "subnet-missing", SecurityGroup: "sg-missing"},
+				},
+				Peerings: []Peering{
+					{Name: "peer", Provider: "aws_test", VPCRequester: "vpc-a", VPCAccepter: "vpc-c"},
+				},
+				KubernetesClusters: []KubernetesCluster{
+					{Name: "eks", Provider: "aws_test", VPC: "vpc-a", Spec: map[string]interface{}{"subnets": []interface{}{"subnet-a", "subnet-b"}}},
+					{Name: "aks", Provider: "azure_test", VPC: "vnet-a", Spec: map[string]interface{}{
+						"node_pools": []interface{}{map[string]interface{}{"name": "apps", "subnet": "subnet-a"}},
+					}},
+				},
+			},
+		},
+	}
+
+	result := &ValidationResult{}
+	decodeSpecs(service, result)
+	validateReferences(service, result)
+
+	got := make(map[string]bool)
+	for _, err := range result.Errors {
+		got[err.Field] = true
+	}
+
+	want := []string{
+		"spec.infrastructure.networks[3].name",
+		"spec.infrastructure.security_groups[1].vpc",
+		"spec.infrastructure.computes[0].subnet",
+		"spec.infrastructure.computes[0].security_group",
+		"spec.infrastructure.computes[1].provider",
+		"spec.infrastructure.computes[1].subnet",
+		"spec.infrastructure.computes[1].security_group",
+		"spec.infrastructure.peerings[0].vpc_accepter",
+		"spec.infrastructure.kubernetes_clusters[0].spec.subnets[1]",
+		"spec.infrastructure.kubernetes_clusters[1].spec.node_pools[0].subnet",
+	}
+	for _, field := range want {
+		if !got[field] {
+			t.Errorf("validateReferences() missing error for %s, got %v", field, result.Errors)
+		}
+	}
+	if len(result.Errors) != len(want) {
+		t.Errorf("validateReferences() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+}
+
+func TestDecodeSpecs(t *testing.T) {
+	service := &Service{
+		Providers: []Provider{
+			{Name: "aws_test", Type: "aws", Spec: map[string]interface{}{"region": "eu-west-1", "enviroment": "local"}},
+			{Name: "gcp_test", Type: "google"},
+			{Name: "azure_test", Type: "azurerm"},
+		},
+		Spec: Spec{
+			Infrastructure: Infrastructure{
+				Computes: []Compute{
+					{Name: "vm-1", Type: "ec2", Provider: "aws_test", Spec: map[string]interface{}{"instance_type": "t3.small", "root_disk_size_gb": 40}},
+					{Name: "vm-2", Type: "ec2", Provider: "aws_test", Spec: map[string]interface{}{"instance_typ": "t3.small"}},
+					{Name: "vm-3", Type: "ec2", Provider: "gcp_test"},
+					{Name: "vm-4", Type: "ec2", Provider: "aws_test", Image: &Image{OS: "windows"}},
+					{Name: "vm-5", Type: "ec2", Provider: "aws_test", Image: &Image{OS: "ubuntu", ID: "ami-0abc"}},
+					{Name: "vm-6", Type: "google_compute_instance", Provider: "gcp_test", Image: &Image{OS: "debian", Arch: "aarch64"}, Spec: map[string]interface{}{"size": "small"}},
+					{Name: "vm-7", Type: "google_compute_instance", Provider: "gcp_test", Spec: map[string]interface{}{"image": "debian-cloud/debian-11"}},
+					{Name: "vm-8", Type: "azurerm_linux_virtual_machine", Provider: "azure_test", Spec: map[string]interface{}{
+						"image":    map[string]interface{}{"publisher": "Canonical", "offer": "UbuntuServer", "sku": "18.04-LTS"},
+						"password": map[string]interface{}{"secret": "env://AZ_VM_PASS"},
+					}},
+					{Name: "vm-9", Type: "google_compute_instance", Provider: "gcp_test", Storage: []Storage{{Name: "data", Size: 10, Type: "pd-ssd"}, {Name: "logs", Size: 10, Type: "gp3"}}},
+					{Name: "vm-10", Type: "ec2", Provider: "aws_test", Storage: make([]Storage, 22)},
+					{Name: "vm-11", Type: "ec2", Provider: "aws_test", Image: &Image{Arch: "arm64"}, Spec: map[string]interface{}{"instance_type": "t3.small"}},
+					{Name: "vm-12", Type: "azurerm_linux_virtual_machine", Provider: "azure_test"},
+				},
+				KubernetesClusters: []KubernetesCluster{
+					{Name: "gke", Provider: "gcp_test", Spec: map[string]interface{}{
+						"machine_type": "e2-standard-4",
+						"node_pools": []interface{}{
+							map[string]interface{}{"name": "apps", "max_nodes": 4},
+							map[string]interface{}{"name": "batch", "max_node": 2},
+						},
+					}},
+					{Name: "eks", Provider: "aws_test", Spec: map[string]interface{}{"node_count": "three"}},
+				},
+			},
+		},
+	}
+
+	result := &ValidationResult{}
+	decodeSpecs(service, result)
+
+	want := []string{
+		"providers[0].spec.enviroment",
+		"spec.infrastructure.computes[1].spec.instance_typ",
+		"spec.infrastructure.computes[2].type",
+		"spec.infrastructure.computes[3].image",
+		"spec.infrastructure.computes[4].image",
+		"spec.infrastructure.computes[6].spec.image",
+		"spec.infrastructure.computes[7].spec.image",
+		"spec.infrastructure.computes[8].storage[1].type",
+		"spec.infrastructure.computes[9].storage",
+		"spec.infrastructure.computes[10].spec",
+		"spec.infrastructure.computes[11].spec",
+		"spec.infrastructure.kubernetes_clusters[0].spec.node_pools[1].max_node",
+		"spec.infrastructure.kubernetes_clusters[1].spec",
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("decodeSpecs() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, field := range want {
+		if result.Errors[i].Field != field {
+			t.Errorf("error %d field = %s, want %s", i, result.Errors[i].Field, field)
+		}
+	}
+	if !strings.Contains(result.Errors[1].Message, "did you mean instance_type?") {
+		t.Errorf("Expected a suggestion for the misspelled key, got %q", result.Errors[1].Message)
+	}
+	if !strings.Contains(result.Errors[5].Message, "image.id: debian-cloud/debian-11") {
+		t.Errorf("Expected the GCP image to move to image.id, got %q", result.Errors[5].Message)
+	}
+	if !strings.Contains(result.Errors[6].Message, "image.id: Canonical:UbuntuServer:18.04-LTS:latest") {
+		t.Errorf("Expected the Azure image to move to image.id, got %q", result.Errors[6].Message)
+	}
+
+	if region := service.Providers[0].TypedSpec.Settings().Region; region != "eu-west-1" {
+		t.Errorf("provider region = %q, want eu-west-1", region)
+	}
+
+	compute := service.Spec.Infrastructure.Computes[0].TypedSpec
+	if compute.InstanceSize() != "t3.small" || compute.RootDiskSizeGB() != 40 {
+		t.Errorf("compute spec = %s/%d, want t3.small/40", compute.InstanceSize(), compute.RootDiskSizeGB())
+	}
+
+	pools := service.Spec.Infrastructure.KubernetesClusters[0].TypedSpec.NodePools()
+	if len(pools) != 2 || pools[0].Name != "apps" || pools[0].Size != "e2-standard-4" || pools[0].Min != 1 || pools[0].Max != 4 {
+		t.Errorf("Unexpected node pools: %+v", pools)
+	}
+}
+
+func TestValidateAddressSpace(t *testing.T) {
+	service := &Service{
+		Spec: Spec{
+			Infrastructure: Infrastructure{
+				Networks: []Network{
+					{
+						Name: "vpc-a",
+						CIDR: "10.0.0.0/16",
+						Subnets: []Subnet{
+							{Name: "subnet-1", CIDR: "10.0.1.0/24"},
+							{Name: "subnet-2", CIDR: "10.0.1.128/25"},
+							{Name: "subnet-3", CIDR: "10.1.0.0/24"},
+							{Name: "subnet-4", CIDR: "10.0.2.0/24"},
+						},
+					},
+					{Name: "vpc-b", CIDR: "10.0.128.0/17"},
+					{Name: "vpc-c", CIDR: "172.16.0.0/16"},
+				},
+				Peerings: []Peering{
+					{Name: "a-to-b", VPCRequester: "vpc-a", VPCAccepter: "vpc-b"},
+					{Name: "a-to-c", VPCRequester: "vpc-a", VPCAccepter: "vpc-c"},
+				},
+			},
+		},
+	}
+
+	result := &ValidationResult{}
+	validateAddressSpace(service, result)
+
+	want := []string{
+		"spec.infrastructure.networks[0].subnets[1].cidr",
+		"spec.infrastructure.networks[0].subnets[2].cidr",
+		"spec.infrastructure.peerings[0].vpc_accepter",
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("validateAddressSpace() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, field := range want {
+		if result.Errors[i].Field != field {
+			t.Errorf("error %d field = %s, want %s", i, result.Errors[i].Field, field)
+		}
+	}
+}
+
 func TestValidateCIDR(t *testing.T) {
 	tests := []struct {
 		name string
@@ -193,3 +501,531 @@ func TestValidateCIDR(t *testing.T) {
 		})
 	}
 }
+
+func TestUserDataValidation(t *testing.T) {
+	service := &Service{
+		Metadata:  Metadata{Name: "test-service"},
+		Providers: []Provider{{Name: "aws_test", Type: "aws"}},
+		Spec: Spec{
+			Infrastructure: Infrastructure{
+				Computes: []Compute{
+					{Name: "ok", Type: "ec2", Provider: "aws_test", UserData: &UserData{Scripts: []string{"echo {{ .Service }}"}}},
+					{Name: "unknown-var", Type: "ec2", Provider: "aws_test", UserData: &UserData{CloudConfig: "hostname: {{ .Hostname }}"}},
+					{Name: "too-large", Type: "ec2", Provider: "aws_test", UserData: &UserData{Scripts: []string{strings.Repeat("#", 17*1024)}}},
+					{Name: "missing-file", Type: "ec2", Provider: "aws_test", UserData: &UserData{File: "does-not-exist.yaml"}},
+					{Name: "too-large-with-storage", Type: "ec2", Provider: "aws_test", UserData: &UserData{Scripts: []string{strings.Repeat("#", 16000)}},
+						Storage: []Storage{{Name: "data", Path: "/data", Size: 10}}},
+				},
+			},
+		},
+	}
+
+	result := &ValidationResult{}
+	decodeSpecs(service, result)
+
+	want := []string{
+		"spec.infrastructure.computes[1].user_data",
+		"spec.infrastructure.computes[2].user_data",
+		"spec.infrastructure.computes[3].user_data",
+		"spec.infrastructure.computes[4].user_data",
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("decodeSpecs() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, field := range want {
+		if result.Errors[i].Field != field {
+			t.Errorf("error %d field = %s, want %s", i, result.Errors[i].Field, field)
+		}
+	}
+	if !strings.Contains(result.Errors[1].Message, "allows at most 16384") {
+		t.Errorf("Expected the AWS size limit in the error, got %q", result.Errors[1].Message)
+	}
+}
+
+func TestSecretRefValidation(t *testing.T) {
+	service := &Service{
+		Providers: []Provider{{Name: "azure_test", Type: "azurerm"}},
+		Spec: Spec{
+			Infrastructure: Infrastructure{
+				Computes: []Compute{
+					{Name: "ref", Type: "azurerm_linux_virtual_machine", Provider: "azure_test", Spec: map[string]interface{}{
+						"password": map[string]interface{}{"secret": "env://AZ_VM_PASS"},
+					}},
+					{Name: "literal", Type: "azurerm_linux_virtual_machine", Provider: "azure_test", Spec: map[string]interface{}{
+						"password": "hunter2",
+					}},
+					{Name: "bad-scheme", Type: "azurerm_linux_virtual_machine", Provider: "azure_test", Spec: map[string]interface{}{
+						"password": map[string]interface{}{"secret": "ssm://vm-pass"},
+					}},
+				},
+			},
+		},
+	}
+
+	result := &ValidationResult{}
+	decodeSpecs(service, result)
+
+	want := []string{
+		"spec.infrastructure.computes[1].spec.password",
+		"spec.infrastructure.computes[2].spec.password.secret",
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("decodeSpecs() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, field := range want {
+		if result.Errors[i].Field != field {
+			t.Errorf("error %d field = %s, want %s", i, result.Errors[i].Field, field)
+		}
+	}
+	if strings.Contains(result.Error(), "hunter2") {
+		t.Error("Expected the validation error not to repeat the literal secret")
+	}
+}
+
+func TestResolveVariables(t *testing.T) {
+	manifest := `
+variables:
+  env:
+    type: string
+    default: dev
+  vpc_cidr:
+    type: string
+  disk_size:
+    type: number
+    default: 20
+  zones:
+    type: list
+    default: [eu-west-1a]
+metadata:
+  name: web-${var.env}
+spec:
+  cidr: ${var.vpc_cidr}
+  size: ${var.disk_size}
+  zones: ${var.zones}
+  script: echo $${var.env} ${HOME}
+`
+	varFile := filepath.Join(t.TempDir(), "prod.yaml")
+	if err := os.WriteFile(varFile, []byte("env: prod\ndisk_size: 50\n"), 0644); err != nil {
+		t.Fatalf("Failed to write variable file: %v", err)
+	}
+	t.Setenv("BOLT_VAR_vpc_cidr", "10.1.0.0/16")
+	t.Setenv("BOLT_VAR_env", "staging")
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(manifest), &document); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if err := resolveVariables(&document, ParseOptions{Vars: []string{"disk_size=100"}, VarFiles: []string{varFile}}); err != nil {
+		t.Fatalf("resolveVariables() error = %v", err)
+	}
+
+	var got struct {
+		Metadata struct{ Name string }
+		Spec     struct {
+			CIDR   string
+			Size   int
+			Zones  []string
+			Script string
+		}
+	}
+	if err := document.Decode(&got); err != nil {
+		t.Fatalf("Failed to decode resolved manifest: %v", err)
+	}
+
+	if got.Metadata.Name != "web-prod" {
+		t.Errorf("name = %q, want web-prod (the variable file overrides the environment)", got.Metadata.Name)
+	}
+	if got.Spec.CIDR != "10.1.0.0/16" {
+		t.Errorf("cidr = %q, want 10.1.0.0/16", got.Spec.CIDR)
+	}
+	if got.Spec.Size != 100 {
+		t.Errorf("size = %d, want 100 (--var overrides the variable file)", got.Spec.Size)
+	}
+	if len(got.Spec.Zones) != 1 || got.Spec.Zones[0] != "eu-west-1a" {
+		t.Errorf("zones = %v, want [eu-west-1a]", got.Spec.Zones)
+	}
+	if got.Spec.Script != "echo ${var.env} ${HOME}" {
+		t.Errorf("script = %q, want the escaped reference and ${HOME} unchanged", got.Spec.Script)
+	}
+}
+
+func TestVariableErrors(t *testing.T) {
+	manifest := `
+variables:
+  count:
+    type: number
+  region:
+    type: string
+  zones:
+    type: list
+    default: [a, b]
+spec:
+  infrastructure:
+    networks:
+      - name: vpc
+        cidr: ${var.cidr}
+        subnets:
+          - zone: ${var.region}
+          - zone: zone-${var.zones}
+    count: ${var.count}
+`
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(manifest), &document); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	err := resolveVariables(&document, ParseOptions{Vars: []string{"count=many", "size=3"}})
+
+	result, ok := err.(*ValidationResult)
+	if !ok {
+		t.Fatalf("resolveVariables() error = %v, want a ValidationResult", err)
+	}
+	want := []struct{ field, message string }{
+		{"--var", "undeclared variable size"},
+		{"variables.count", "is not a number"},
+		{"spec.infrastructure.networks[0].cidr", "undefined variable cidr (line 14, column 15)"},
+		{"spec.infrastructure.networks[0].subnets[0].zone", "variable region has no value (line 16, column 19)"},
+		{"spec.infrastructure.networks[0].subnets[1].zone", "list variable zones cannot be part of a string"},
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("resolveVariables() returned %d errors, want %d: %v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, w := range want {
+		if result.Errors[i].Field != w.field || !strings.Contains(result.Errors[i].Message, w.message) {
+			t.Errorf("error %d = %s: %s, want %s: ...%s...", i, result.Errors[i].Field, result.Errors[i].Message, w.field, w.message)
+		}
+	}
+}
+
+func TestApplyOverlay(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "service.yaml")
+	base := `
+metadata:
+  name: web
+  tags:
+    team: platform
+    environment: local
+spec:
+  infrastructure:
+    networks:
+      - name: vpc
+        cidr: 10.0.0.0/16
+        subnets:
+          - {name: a, cidr: 10.0.1.0/24}
+          - {name: b, cidr: 10.0.2.0/24}
+    computes:
+      - name: web
+        spec: {instance_type: t3.micro, root_disk_size_gb: 20}
+        storage: [{name: data, size: 10}]
+      - name: debug
+        spec: {instance_type: t3.micro}
+`
+	overlay := `
+metadata:
+  tags:
+    team: null
+spec:
+  infrastructure:
+    networks:
+      - name: vpc
+        cidr: 10.1.0.0/16
+        subnets:
+          - {name: b, $patch: delete}
+          - {name: c, cidr: 10.1.3.0/24}
+    computes:
+      - name: web
+        spec: {instance_type: m5.large}
+        storage: [{name: logs, size: 50}]
+      - name: debug
+        $patch: replace
+        spec: {instance_type: t3.nano}
+`
+	if err := os.WriteFile(manifestPath, []byte(base), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "overlays"), 0755); err != nil {
+		t.Fatalf("Failed to create overlays directory: %v", err)
+	}
+	if err := os.WriteFile(OverlayPath(manifestPath, "prod"), []byte(overlay), 0644); err != nil {
+		t.Fatalf("Failed to write overlay: %v", err)
+	}
+
+	document, err := loadManifest(manifestPath, ParseOptions{Env: "prod"})
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+	var service Service
+	if err := document.Decode(&service); err != nil {
+		t.Fatalf("Failed to decode merged manifest: %v", err)
+	}
+
+	if tags := service.Metadata.Tags; tags["environment"] != "prod" || tags["team"] != "" {
+		t.Errorf("tags = %v, want the environment tag overridden and team removed", tags)
+	}
+	network := service.Spec.Infrastructure.Networks[0]
+	if network.CIDR != "10.1.0.0/16" {
+		t.Errorf("network cidr = %s, want 10.1.0.0/16", network.CIDR)
+	}
+	if len(network.Subnets) != 2 || network.Subnets[0].Name != "a" || network.Subnets[1].Name != "c" {
+		t.Errorf("subnets = %+v, want a and c", network.Subnets)
+	}
+
+	web := service.Spec.Infrastructure.Computes[0]
+	if web.Spec["instance_type"] != "m5.large" || web.Spec["root_disk_size_gb"] != 20 {
+		t.Errorf("web spec = %v, want instance_type patched and root_disk_size_gb kept", web.Spec)
+	}
+	if len(web.Storage) != 2 {
+		t.Errorf("web storage = %+v, want data and logs", web.Storage)
+	}
+	debug := service.Spec.Infrastructure.Computes[1]
+	if debug.Spec["instance_type"] != "t3.nano" || len(debug.Storage) != 0 {
+		t.Errorf("debug = %+v, want it replaced", debug)
+	}
+
+	if _, err := loadManifest(manifestPath, ParseOptions{Env: "staging"}); err == nil || !strings.Contains(err.Error(), "no overlay for environment staging") {
+		t.Errorf("loadManifest() with a missing overlay error = %v", err)
+	}
+	if err := os.WriteFile(OverlayPath(manifestPath, "bad"), []byte("spec:\n  infrastructure:\n    computes:\n      - {name: nope, $patch: delete}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write overlay: %v", err)
+	}
+	if _, err := loadManifest(manifestPath, ParseOptions{Env: "bad"}); err == nil || !strings.Contains(err.Error(), "computes[name=nope]") {
+		t.Errorf("loadManifest() deleting an unknown item error = %v", err)
+	}
+}
+
+func TestResolveImports(t *testing.T) {
+	dir := t.TempDir()
+	library := filepath.Join(dir, "library")
+	files := map[string]string{
+		"library/standard-vpc.yaml": `
+kind: Component
+parameters:
+  provider: {type: string}
+  cidr: {type: string, default: 10.0.0.0/16}
+  subnet_cidr: {type: string}
+imports:
+  - name: sg
+    source: ../components/web-sg.yaml
+    parameters: {provider: "${param.provider}", vpc: vpc}
+spec:
+  infrastructure:
+    networks:
+      - name: vpc
+        provider: ${param.provider}
+        cidr: ${param.cidr}
+        subnets:
+          - {name: public, zone: us-east-1a, cidr: "${param.subnet_cidr}"}
+`,
+		"components/web-sg.yaml": `
+kind: Component
+parameters:
+  provider: {type: string}
+  vpc: {type: string}
+spec:
+  infrastructure:
+    security_groups:
+      - name: web
+        provider: ${param.provider}
+        vpc: ${param.vpc}
+        rules:
+          - {type: ingress, protocol: tcp, from_port: 443, to_port: 443, source_vpc: shared}
+`,
+		"components/region.yaml": "kind: Component\nspec:\n  infrastructure:\n    networks:\n      - {name: vpc, provider: \"aws_${var.region}\"}\n",
+		"components/loop-a.yaml": "kind: Component\nimports:\n  - {name: b, source: ./loop-b.yaml}\n",
+		"components/loop-b.yaml": "kind: Component\nimports:\n  - {name: a, source: ./loop-a.yaml}\n",
+		"service.yaml": `
+kind: Service
+imports:
+  - name: main
+    source: standard-vpc
+    parameters: {provider: aws_main, subnet_cidr: 10.0.1.0/24}
+spec:
+  infrastructure:
+    networks:
+      - {name: shared, provider: aws_main, cidr: 10.9.0.0/16}
+    computes:
+      - {name: web, vpc: main-vpc, subnet: main-public, security_group: main-sg-web}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	document, err := loadManifest(filepath.Join(dir, "service.yaml"), ParseOptions{LibraryDir: library})
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+	var service Service
+	if err := document.Decode(&service); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+
+	infra := service.Spec.Infrastructure
+	if len(infra.Networks) != 2 || infra.Networks[1].Name != "main-vpc" || infra.Networks[1].CIDR != "10.0.0.0/16" {
+		t.Fatalf("networks = %+v, want shared and main-vpc with the default cidr", infra.Networks)
+	}
+	if subnet := infra.Networks[1].Subnets[0]; subnet.Name != "main-public" || subnet.CIDR != "10.0.1.0/24" {
+		t.Errorf("subnet = %+v, want main-public 10.0.1.0/24", subnet)
+	}
+	if len(infra.SecurityGroups) != 1 {
+		t.Fatalf("security groups = %+v, want the one of the nested component", infra.SecurityGroups)
+	}
+	sg := infra.SecurityGroups[0]
+	if sg.Name != "main-sg-web" || sg.VPC != "main-vpc" || sg.Provider != "aws_main" {
+		t.Errorf("security group = %+v, want main-sg-web in main-vpc", sg)
+	}
+	if sg.Rules[0].SourceVPC != "shared" {
+		t.Errorf("source_vpc = %s, want the service network shared left unprefixed", sg.Rules[0].SourceVPC)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		opts     ParseOptions
+		want     string
+	}{
+		{"cycle", "kind: Service\nimports:\n  - {name: a, source: ./components/loop-a.yaml}\n", ParseOptions{}, "import cycle"},
+		{"no library", "kind: Service\nimports:\n  - {name: a, source: standard-vpc}\n", ParseOptions{}, "no component library is configured"},
+		{"missing parameter", "kind: Service\nimports:\n  - {name: a, source: standard-vpc, parameters: {provider: aws}}\n", ParseOptions{LibraryDir: library}, "parameter subnet_cidr has no value"},
+		{"undeclared parameter", "kind: Service\nimports:\n  - {name: a, source: standard-vpc, parameters: {provider: aws, subnet_cidr: 10.0.1.0/24, size: 3}}\n", ParseOptions{LibraryDir: library}, "undeclared parameter size"},
+		{"not a component", "kind: Service\nimports:\n  - {name: a, source: ./service.yaml}\n", ParseOptions{}, "is not a Component"},
+		{"variable", "kind: Service\nvariables:\n  region: {default: eu}\nimports:\n  - {name: a, source: ./components/region.yaml}\n", ParseOptions{}, "variable region cannot be used in a component"},
+		{"duplicate name", "kind: Service\nimports:\n  - {name: a, source: ./components/web-sg.yaml}\n  - {name: a, source: ./components/web-sg.yaml}\n", ParseOptions{}, "duplicate import name a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "case.yaml")
+			if err := os.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+				t.Fatalf("Failed to write manifest: %v", err)
+			}
+			if _, err := loadManifest(path, tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadManifest() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlayPatchesComponents(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"components/app.yaml": `
+kind: Component
+parameters:
+  instance_type: {type: string, default: t3.micro}
+spec:
+  infrastructure:
+    computes:
+      - name: web
+        provider: aws_main
+        spec: {instance_type: "${param.instance_type}", root_disk_size_gb: 20}
+      - name: worker
+        provider: aws_main
+        spec: {instance_type: "${param.instance_type}"}
+`,
+		"service.yaml": `
+kind: Service
+variables:
+  disk_size: {type: number, default: 20}
+imports:
+  - name: app
+    source: ./components/app.yaml
+`,
+		"overlays/prod.yaml": `
+variables:
+  disk_size: {default: 100}
+imports:
+  - name: app
+    parameters: {instance_type: m5.large}
+spec:
+  infrastructure:
+    computes:
+      - name: app-web
+        spec: {root_disk_size_gb: "${var.disk_size}"}
+      - {name: app-worker, $patch: delete}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	document, err := loadManifest(filepath.Join(dir, "service.yaml"), ParseOptions{Env: "prod"})
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+	var service Service
+	if err := document.Decode(&service); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+
+	computes := service.Spec.Infrastructure.Computes
+	if len(computes) != 1 || computes[0].Name != "app-web" {
+		t.Fatalf("computes = %+v, want app-worker deleted by the overlay", computes)
+	}
+	if spec := computes[0].Spec; spec["instance_type"] != "m5.large" || spec["root_disk_size_gb"] != 100 {
+		t.Errorf("app-web spec = %v, want the overlay import parameter and disk size", spec)
+	}
+}
+
+func TestUserDataFilePaths(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"components/app.yaml": "kind: Component\nspec:\n  infrastructure:\n    computes:\n      - {name: web, user_data: {file: scripts/web.sh}}\n",
+		"service.yaml": `
+kind: Service
+imports:
+  - {name: app, source: ./components/app.yaml}
+spec:
+  infrastructure:
+    computes:
+      - {name: base, user_data: {file: scripts/base.sh}}
+      - {name: home, user_data: {file: ~/setup.sh}}
+`,
+		"overlays/prod.yaml": "spec:\n  infrastructure:\n    computes:\n      - {name: prod, user_data: {file: prod.sh}}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	document, err := loadManifest(filepath.Join(dir, "service.yaml"), ParseOptions{Env: "prod"})
+	if err != nil {
+		t.Fatalf("loadManifest() error = %v", err)
+	}
+	var service Service
+	if err := document.Decode(&service); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+
+	want := map[string]string{
+		"base":    filepath.Join(dir, "scripts/base.sh"),
+		"home":    "~/setup.sh",
+		"app-web": filepath.Join(dir, "components/scripts/web.sh"),
+		"prod":    filepath.Join(dir, "overlays/prod.sh"),
+	}
+	for _, compute := range service.Spec.Infrastructure.Computes {
+		if compute.UserData.File != want[compute.Name] {
+			t.Errorf("%s user_data.file = %s, want %s", compute.Name, compute.UserData.File, want[compute.Name])
+		}
+		delete(want, compute.Name)
+	}
+	if len(want) != 0 {
+		t.Errorf("computes missing from the manifest: %v", want)
+	}
+}
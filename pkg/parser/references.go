@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// symbol records where a named manifest object is declared.
+type symbol struct {
+	Path     string
+	Provider string
+	Network  string
+}
+
+// symbolTable indexes the named objects of a manifest so references can be checked.
+type symbolTable struct {
+	providers      map[string]symbol
+	networks       map[string]symbol
+	subnets        map[string]symbol
+	securityGroups map[string]symbol
+}
+
+// validateReferences checks that every name used to refer to another manifest object
+// resolves, that names are unique per kind, and that related objects live in the same
+// provider and network. Errors use the same field paths as the per-object validation.
+func validateReferences(service *Service, result *ValidationResult) {
+	symbols := buildSymbolTable(service, result)
+	infra := service.Spec.Infrastructure
+	path := "spec.infrastructure"
+
+	for i, network := range infra.Networks {
+		networkPath := fmt.Sprintf("%s.networks[%d]", path, i)
+		symbols.checkProvider(network.Provider, networkPath+".provider", result)
+	}
+
+	for i, peering := range infra.Peerings {
+		peeringPath := fmt.Sprintf("%s.peerings[%d]", path, i)
+		symbols.checkProvider(peering.Provider, peeringPath+".provider", result)
+		symbols.checkNetwork(peering.VPCRequester, peering.Provider, peeringPath+".vpc_requester", result)
+		symbols.checkNetwork(peering.VPCAccepter, peering.Provider, peeringPath+".vpc_accepter", result)
+	}
+
+	for i, sg := range infra.SecurityGroups {
+		sgPath := fmt.Sprintf("%s.security_groups[%d]", path, i)
+		symbols.checkProvider(sg.Provider, sgPath+".provider", result)
+		symbols.checkNetwork(sg.VPC, sg.Provider, sgPath+".vpc", result)
+
+		for j, rule := range sg.Rules {
+			if rule.SourceVPC != "" {
+				if _, exists := symbols.networks[rule.SourceVPC]; !exists {
+					result.AddError(fmt.Sprintf("%s.rules[%d].source_vpc", sgPath, j), fmt.Sprintf("source VPC not found: %s", rule.SourceVPC))
+				}
+			}
+		}
+	}
+
+	for i, cluster := range infra.KubernetesClusters {
+		clusterPath := fmt.Sprintf("%s.kubernetes_clusters[%d]", path, i)
+		symbols.checkProvider(cluster.Provider, clusterPath+".provider", result)
+		symbols.checkNetwork(cluster.VPC, cluster.Provider, clusterPath+".vpc", result)
+
+		if cluster.TypedSpec == nil {
+			continue
+		}
+		for j, subnet := range cluster.TypedSpec.SubnetNames() {
+			symbols.checkSubnet(subnet, cluster.VPC, fmt.Sprintf("%s.spec.subnets[%d]", clusterPath, j), result)
+		}
+		for j, pool := range cluster.TypedSpec.NodePools() {
+			// Only pools listed under node_pools can set a subnet, so j indexes that list.
+			if pool.Subnet != "" {
+				symbols.checkSubnet(pool.Subnet, cluster.VPC, fmt.Sprintf("%s.spec.node_pools[%d].subnet", clusterPath, j), result)
+			}
+		}
+	}
+
+	for i, compute := range infra.Computes {
+		computePath := fmt.Sprintf("%s.computes[%d]", path, i)
+		symbols.checkProvider(compute.Provider, computePath+".provider", result)
+		symbols.checkNetwork(compute.VPC, compute.Provider, computePath+".vpc", result)
+
+		if compute.Subnet != "" {
+			if subnet, exists := symbols.subnets[compute.Subnet]; !exists {
+				result.AddError(computePath+".subnet", fmt.Sprintf("subnet not found: %s", compute.Subnet))
+			} else if compute.VPC != "" && subnet.Network != compute.VPC {
+				result.AddError(computePath+".subnet", fmt.Sprintf("subnet %s belongs to VPC %s, not %s", compute.Subnet, subnet.Network, compute.VPC))
+			}
+		}
+
+		if compute.SecurityGroup != "" {
+			if sg, exists := symbols.securityGroups[compute.SecurityGroup]; !exists {
+				result.AddError(computePath+".security_group", fmt.Sprintf("security group not found: %s", compute.SecurityGroup))
+			} else if compute.VPC != "" && sg.Network != compute.VPC {
+				result.AddError(computePath+".security_group", fmt.Sprintf("security group %s belongs to VPC %s, not %s", compute.SecurityGroup, sg.Network, compute.VPC))
+			}
+		}
+	}
+}
+
+// buildSymbolTable indexes providers, networks, subnets and security groups, reporting duplicate names.
+// Computes, clusters and peerings are not referenced by other objects, but their names must still be
+// unique because they become OpenTofu resource names.
+func buildSymbolTable(service *Service, result *ValidationResult) *symbolTable {
+	symbols := &symbolTable{
+		providers:      make(map[string]symbol),
+		networks:       make(map[string]symbol),
+		subnets:        make(map[string]symbol),
+		securityGroups: make(map[string]symbol),
+	}
+	infra := service.Spec.Infrastructure
+	path := "spec.infrastructure"
+
+	for i, provider := range service.Providers {
+		declare(symbols.providers, provider.Name, symbol{Path: fmt.Sprintf("providers[%d]", i)}, "provider", result)
+	}
+
+	for i, network := range infra.Networks {
+		networkPath := fmt.Sprintf("%s.networks[%d]", path, i)
+		declare(symbols.networks, network.Name, symbol{Path: networkPath, Provider: network.Provider}, "network", result)
+
+		for j, subnet := range network.Subnets {
+			subnetPath := fmt.Sprintf("%s.subnets[%d]", networkPath, j)
+			declare(symbols.subnets, subnet.Name, symbol{Path: subnetPath, Provider: network.Provider, Network: network.Name}, "subnet", result)
+		}
+	}
+
+	for i, sg := range infra.SecurityGroups {
+		sgPath := fmt.Sprintf("%s.security_groups[%d]", path, i)
+		declare(symbols.securityGroups, sg.Name, symbol{Path: sgPath, Provider: sg.Provider, Network: sg.VPC}, "security group", result)
+	}
+
+	peerings := make(map[string]symbol)
+	for i, peering := range infra.Peerings {
+		declare(peerings, peering.Name, symbol{Path: fmt.Sprintf("%s.peerings[%d]", path, i)}, "peering", result)
+	}
+
+	clusters := make(map[string]symbol)
+	for i, cluster := range infra.KubernetesClusters {
+		declare(clusters, cluster.Name, symbol{Path: fmt.Sprintf("%s.kubernetes_clusters[%d]", path, i)}, "kubernetes cluster", result)
+	}
+
+	computes := make(map[string]symbol)
+	for i, compute := range infra.Computes {
+		declare(computes, compute.Name, symbol{Path: fmt.Sprintf("%s.computes[%d]", path, i)}, "compute", result)
+	}
+
+	return symbols
+}
+
+// checkSubnet reports a subnet reference that is undeclared or outside the given VPC.
+func (s *symbolTable) checkSubnet(name, vpc, field string, result *ValidationResult) {
+	if subnet, exists := s.subnets[name]; !exists {
+		result.AddError(field, fmt.Sprintf("subnet not found: %s", name))
+	} else if vpc != "" && subnet.Network != vpc {
+		result.AddError(field, fmt.Sprintf("subnet %s belongs to VPC %s, not %s", name, subnet.Network, vpc))
+	}
+}
+
+// declare adds a name to a symbol table, reporting a duplicate if it is already declared.
+// Empty names are skipped; they are reported by the per-object validation.
+func declare(table map[string]symbol, name string, sym symbol, kind string, result *ValidationResult) {
+	if name == "" {
+		return
+	}
+	if existing, exists := table[name]; exists {
+		result.AddError(sym.Path+".name", fmt.Sprintf("duplicate %s name %s (already declared at %s)", kind, name, existing.Path))
+		return
+	}
+	table[name] = sym
+}
+
+// checkProvider reports a provider reference that does not match any entry in providers.
+func (s *symbolTable) checkProvider(name, field string, result *ValidationResult) {
+	if name == "" {
+		return
+	}
+	if _, exists := s.providers[name]; !exists {
+		result.AddError(field, fmt.Sprintf("provider not found: %s", name))
+	}
+}
+
+// checkNetwork reports a network reference that does not exist or belongs to another provider.
+func (s *symbolTable) checkNetwork(name, provider, field string, result *ValidationResult) {
+	if name == "" {
+		return
+	}
+	network, exists := s.networks[name]
+	if !exists {
+		result.AddError(field, fmt.Sprintf("VPC not found: %s", name))
+		return
+	}
+	// An undeclared provider is already reported by checkProvider.
+	if _, declared := s.providers[provider]; declared && network.Provider != provider {
+		result.AddError(field, fmt.Sprintf("VPC %s belongs to provider %s, not %s", name, network.Provider, provider))
+	}
+}
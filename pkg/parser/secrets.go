@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"bold/pkg/secrets"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretRef refers to a secret kept outside the manifest, e.g. {secret: env://AZ_VM_PASS}.
+// Spec fields that hold secrets use it instead of a string, so literal secrets fail validation.
+type SecretRef struct {
+	Secret string `yaml:"secret"`
+}
+
+var secretRefType = reflect.TypeOf(SecretRef{})
+
+// UnmarshalYAML leaves the reference empty for a literal value; checkSpecFields reports it.
+func (r *SecretRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	type plain SecretRef
+	return node.Decode((*plain)(r))
+}
+
+// checkSecretRef reports literal secrets and malformed secret references.
+func checkSecretRef(value interface{}, path string, result *ValidationResult) {
+	ref, ok := value.(map[string]interface{})
+	if !ok {
+		result.AddError(path, fmt.Sprintf("literal secrets are not allowed; use a reference such as {secret: env://NAME} (schemes: %s)", strings.Join(secrets.Schemes(), ", ")))
+		return
+	}
+
+	fields := specFields(secretRefType)
+	for _, key := range sortedKeys(ref) {
+		if _, known := fields[key]; !known {
+			result.AddError(path+"."+key, unknownFieldMessage(key, fields))
+		}
+	}
+
+	secret, _ := ref["secret"].(string)
+	if secret == "" {
+		result.AddError(path+".secret", "secret reference is required")
+		return
+	}
+	if err := secrets.Validate(secret); err != nil {
+		result.AddError(path+".secret", err.Error())
+	}
+}
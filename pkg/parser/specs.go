@@ -0,0 +1,481 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"bold/pkg/catalog"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderSettings holds the provider spec keys every provider type accepts. Provider
+// spec structs embed it with `yaml:",inline"`.
+type ProviderSettings struct {
+	Region      string `yaml:"region,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+}
+
+// Settings returns the shared settings, which makes every struct embedding ProviderSettings a ProviderSpec.
+func (s *ProviderSettings) Settings() *ProviderSettings {
+	return s
+}
+
+// ProviderSpec is the typed form of a provider spec.
+type ProviderSpec interface {
+	Settings() *ProviderSettings
+}
+
+// ComputeSpec is the typed form of a compute spec. Every compute type decodes its spec into
+// its own struct; the methods expose what cost estimation needs from all of them.
+type ComputeSpec interface {
+	// InstanceSize returns the instance type, VM size or machine type, defaults applied.
+	InstanceSize() string
+	// RootDiskSizeGB returns the root disk size, or 0 for the image default.
+	RootDiskSizeGB() int
+}
+
+// ComputeSizing holds the provider-agnostic sizing keys every compute spec accepts. Compute
+// spec structs embed it with `yaml:",inline"`.
+type ComputeSizing struct {
+	// Size is a size class (small, medium, large, xlarge) or an instance type of the provider.
+	Size     string  `yaml:"size,omitempty"`
+	CPU      int     `yaml:"cpu,omitempty"`
+	MemoryGB float64 `yaml:"memory_gb,omitempty"`
+	// Arch is the normalized architecture of the compute image, set by DecodeSpecs so size
+	// classes resolve to instance types that can boot it. Empty selects amd64.
+	Arch string `yaml:"-"`
+}
+
+// Sizing returns the sizing keys, which makes every struct embedding ComputeSizing a SizedSpec.
+func (s *ComputeSizing) Sizing() *ComputeSizing {
+	return s
+}
+
+// SizedSpec is implemented by compute specs that accept the provider-agnostic sizing keys.
+type SizedSpec interface {
+	Sizing() *ComputeSizing
+}
+
+// CheckedSpec is implemented by compute specs with rules that span the rest of the manifest.
+// Check reports a spec that would compile to an invalid resource.
+type CheckedSpec interface {
+	Check(service *Service) error
+}
+
+// IsClass reports whether Size names a size class rather than an instance type.
+func (s *ComputeSizing) IsClass() bool {
+	_, ok := catalog.SizeClass(s.Size)
+	return ok
+}
+
+// Resolve returns the instance type the sizing keys select for a provider type, or "" when
+// none are set. A size class and cpu/memory_gb resolve to the cheapest catalog instance type
+// of Arch with at least their combined capacity; any other size is used as the instance type.
+func (s *ComputeSizing) Resolve(providerType string) (string, error) {
+	requirements := catalog.Requirements{CPU: s.CPU, MemoryGB: s.MemoryGB, Arch: s.Arch}
+
+	switch {
+	case s.IsClass():
+		class, _ := catalog.SizeClass(s.Size)
+		requirements.CPU = max(requirements.CPU, class.CPU)
+		requirements.MemoryGB = max(requirements.MemoryGB, class.MemoryGB)
+	case s.Size != "":
+		if s.CPU > 0 || s.MemoryGB > 0 {
+			return "", fmt.Errorf("cpu and memory_gb can only be combined with a size class (%s), not instance type %s",
+				strings.Join(catalog.SizeClasses(), ", "), s.Size)
+		}
+		return s.Size, nil
+	case s.CPU == 0 && s.MemoryGB == 0:
+		return "", nil
+	}
+
+	instanceType, err := catalog.Cheapest(providerType, requirements)
+	if err != nil {
+		return "", err
+	}
+	return instanceType.Name, nil
+}
+
+// OSImage returns the catalog image the compute selects; a nil Image selects the default image.
+func (i *Image) OSImage() catalog.OSImage {
+	if i == nil {
+		return catalog.DefaultImage
+	}
+	return catalog.OSImage{OS: i.OS, Version: i.Version, Arch: i.Arch}
+}
+
+// arch returns the normalized architecture of the image, or "" when it is selected by id or
+// is invalid, which check reports.
+func (i *Image) arch() string {
+	if i == nil || i.ID != "" {
+		return ""
+	}
+	image, err := catalog.NormalizeImage(i.OSImage())
+	if err != nil {
+		return ""
+	}
+	return image.Arch
+}
+
+// checkArch reports a catalog instance type that cannot boot an image of arch. Instance types
+// outside the catalog are not checked.
+func checkArch(providerType, instanceType, arch string) error {
+	if arch == "" {
+		arch = catalog.DefaultImage.Arch
+	}
+	known, ok := catalog.LookupInstanceType(providerType, instanceType)
+	if !ok || known.Arch == arch {
+		return nil
+	}
+	return fmt.Errorf("instance type %s is %s but the image is %s; set a size class or an %s instance type", instanceType, known.Arch, arch, arch)
+}
+
+// check reports an image that is not published on a provider type.
+func (i *Image) check(providerType string) error {
+	if i == nil {
+		return nil
+	}
+	if i.ID != "" {
+		if i.OS != "" || i.Version != "" || i.Arch != "" {
+			return errors.New("id selects the image on its own and cannot be combined with os, version or arch")
+		}
+		return nil
+	}
+	return catalog.CheckImage(providerType, i.OSImage())
+}
+
+// legacyImageMessage explains how to move the image of a compute spec, the Azure
+// {publisher, offer, sku, version} mapping or the GCP image name, to the image key.
+func legacyImageMessage(legacy interface{}) string {
+	const message = "spec.image was replaced by the image key of the compute; set image.os, image.version and image.arch, or image.id"
+	switch v := legacy.(type) {
+	case string:
+		return fmt.Sprintf("%s: %s", message, v)
+	case map[string]interface{}:
+		version, ok := v["version"]
+		if !ok {
+			version = "latest"
+		}
+		return fmt.Sprintf("%s: %v:%v:%v:%v", message, v["publisher"], v["offer"], v["sku"], version)
+	default:
+		return message
+	}
+}
+
+// ClusterSpec is the typed form of a Kubernetes cluster spec. Every provider type decodes
+// cluster specs into its own struct.
+type ClusterSpec interface {
+	// NodePools returns the node pools of the cluster, defaults applied.
+	NodePools() []NodePool
+	// SubnetNames returns the manifest subnets listed in the spec, or nil for all subnets of the VPC.
+	SubnetNames() []string
+}
+
+// NodePool is a Kubernetes node pool with its defaults applied.
+type NodePool struct {
+	// Name is empty for the pool built from the top-level spec keys when no node pools are listed.
+	Name   string
+	Size   string
+	Count  int
+	Min    int
+	Max    int
+	Subnet string
+	// DiskSizeGB is the node disk size, or 0 for the provider default.
+	DiskSizeGB int
+}
+
+// Autoscaling reports whether the pool scales between Min and Max nodes.
+func (p NodePool) Autoscaling() bool {
+	return p.Max > 0
+}
+
+// SpecTypes describes the typed specs of a provider type. Each function returns a new,
+// empty spec for a manifest spec to be decoded into.
+type SpecTypes struct {
+	Provider func() ProviderSpec
+	Cluster  func() ClusterSpec
+	// Computes is keyed by compute `type`.
+	Computes map[string]func() ComputeSpec
+	// StorageTypes are the disk types compute storage can use; empty accepts any type.
+	StorageTypes []string
+	// MaxVolumes is the number of storage entries a compute can attach, or 0 for no limit.
+	MaxVolumes int
+	// UserDataLimit is the maximum size in bytes of compute user data, or 0 for no limit.
+	UserDataLimit int
+	// UserData renders the user data of a compute the way the backend compiles it, including
+	// the parts the backend adds such as volume mounts, so that its size can be checked
+	// against UserDataLimit. When nil only the parts of user_data are counted.
+	UserData func(service *Service, compute Compute) string
+}
+
+// specTypes holds the typed specs of every provider type that has a registered backend.
+// It is filled by provider.Register, which parser cannot import without a cycle, so
+// validation and compilation always agree on the supported clouds and spec keys.
+var specTypes = make(map[string]SpecTypes)
+
+// RegisterProviderType marks a provider type as supported and registers its typed specs.
+func RegisterProviderType(providerType string, types SpecTypes) {
+	specTypes[providerType] = types
+}
+
+// DecodeSpecs decodes the provider, compute and Kubernetes cluster specs of the service into
+// the typed structs registered for their types and stores them in TypedSpec. Unknown keys
+// and values of the wrong type are reported as validation errors.
+func DecodeSpecs(service *Service) error {
+	result := &ValidationResult{}
+	decodeSpecs(service, result)
+	if result.HasErrors() {
+		return result
+	}
+	return nil
+}
+
+func decodeSpecs(service *Service, result *ValidationResult) {
+	for i := range service.Providers {
+		p := &service.Providers[i]
+		types, ok := specTypes[p.Type]
+		if !ok {
+			// Unsupported provider types are reported by validateProvider.
+			continue
+		}
+		p.TypedSpec = types.Provider()
+		decodeSpec(p.Spec, p.TypedSpec, fmt.Sprintf("providers[%d].spec", i), result)
+	}
+
+	infra := &service.Spec.Infrastructure
+	for i := range infra.Computes {
+		compute := &infra.Computes[i]
+		path := fmt.Sprintf("spec.infrastructure.computes[%d]", i)
+
+		providerType, known := resolveProviderType(service, compute.Provider)
+		if !known || compute.Type == "" {
+			// Missing or undeclared providers and types are reported by the other checks.
+			continue
+		}
+		newSpec, ok := specTypes[providerType].Computes[compute.Type]
+		if !ok {
+			result.AddError(path+".type", fmt.Sprintf("compute type %s is not supported by provider %s (supported: %s)",
+				compute.Type, compute.Provider, strings.Join(sortedKeys(specTypes[providerType].Computes), ", ")))
+			continue
+		}
+		compute.TypedSpec = newSpec()
+		spec := compute.Spec
+		if legacy, ok := spec["image"]; ok {
+			result.AddError(path+".spec.image", legacyImageMessage(legacy))
+			spec = maps.Clone(spec)
+			delete(spec, "image")
+		}
+		decodeSpec(spec, compute.TypedSpec, path+".spec", result)
+		if checked, ok := compute.TypedSpec.(CheckedSpec); ok {
+			if err := checked.Check(service); err != nil {
+				result.AddError(path+".spec", err.Error())
+			}
+		}
+
+		arch := compute.Image.arch()
+		sizingErr := false
+		if sized, ok := compute.TypedSpec.(SizedSpec); ok {
+			sized.Sizing().Arch = arch
+			if _, err := sized.Sizing().Resolve(providerType); err != nil {
+				result.AddError(path+".spec", err.Error())
+				sizingErr = true
+			}
+		}
+		if err := compute.Image.check(providerType); err != nil {
+			result.AddError(path+".image", err.Error())
+		} else if !sizingErr {
+			if err := checkArch(providerType, compute.TypedSpec.InstanceSize(), arch); err != nil {
+				result.AddError(path+".spec", err.Error())
+			}
+		}
+
+		parts, err := compute.UserData.Parts(UserDataVarsFor(service, *compute))
+		if err != nil {
+			result.AddError(path+".user_data", err.Error())
+		} else if limit := specTypes[providerType].UserDataLimit; limit > 0 {
+			size := userDataSize(parts)
+			if render := specTypes[providerType].UserData; render != nil {
+				size = len(render(service, *compute))
+			}
+			if size > limit {
+				result.AddError(path+".user_data", fmt.Sprintf("rendered user data is %d bytes, provider %s allows at most %d", size, compute.Provider, limit))
+			}
+		}
+
+		if limit := specTypes[providerType].MaxVolumes; limit > 0 && len(compute.Storage) > limit {
+			result.AddError(path+".storage", fmt.Sprintf("compute has %d storage entries, provider %s allows at most %d", len(compute.Storage), compute.Provider, limit))
+		}
+		if storageTypes := specTypes[providerType].StorageTypes; len(storageTypes) > 0 {
+			for j, storage := range compute.Storage {
+				// A missing type is reported by validateStorage.
+				if storage.Type != "" && !slices.Contains(storageTypes, storage.Type) {
+					result.AddError(fmt.Sprintf("%s.storage[%d].type", path, j), fmt.Sprintf("storage type %s is not supported by provider %s (supported: %s)",
+						storage.Type, compute.Provider, strings.Join(storageTypes, ", ")))
+				}
+			}
+		}
+	}
+
+	for i := range infra.KubernetesClusters {
+		cluster := &infra.KubernetesClusters[i]
+		providerType, known := resolveProviderType(service, cluster.Provider)
+		if !known {
+			continue
+		}
+		cluster.TypedSpec = specTypes[providerType].Cluster()
+		decodeSpec(cluster.Spec, cluster.TypedSpec, fmt.Sprintf("spec.infrastructure.kubernetes_clusters[%d].spec", i), result)
+	}
+}
+
+// resolveProviderType returns the type of the manifest provider a resource refers to.
+func resolveProviderType(service *Service, name string) (string, bool) {
+	for _, p := range service.Providers {
+		if p.Name == name {
+			_, ok := specTypes[p.Type]
+			return p.Type, ok
+		}
+	}
+	return "", false
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^line \d+: `)
+
+// decodeSpec decodes an untyped manifest spec into target, reporting unknown keys and
+// values of the wrong type under path.
+func decodeSpec(spec map[string]interface{}, target interface{}, path string, result *ValidationResult) {
+	if len(spec) == 0 {
+		return
+	}
+
+	checkSpecFields(spec, reflect.TypeOf(target), path, result)
+
+	// The spec was already parsed once as part of the manifest, so round-tripping it
+	// through YAML lets yaml.v3 apply the struct tags and type conversions.
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		result.AddError(path, fmt.Sprintf("failed to encode spec: %v", err))
+		return
+	}
+	if err := yaml.Unmarshal(data, target); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			result.AddError(path, err.Error())
+			return
+		}
+		// Line numbers refer to the re-encoded spec, not the manifest, so they are dropped.
+		for _, message := range typeErr.Errors {
+			result.AddError(path, yamlLinePrefix.ReplaceAllString(message, ""))
+		}
+	}
+}
+
+// checkSpecFields reports the keys of value that have no matching field in t, recursing
+// into nested structs and lists of structs.
+func checkSpecFields(value interface{}, t reflect.Type, path string, result *ValidationResult) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == secretRefType {
+		checkSecretRef(value, path, result)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := specFields(t)
+		for _, key := range sortedKeys(values) {
+			field, known := fields[key]
+			if !known {
+				result.AddError(path+"."+key, unknownFieldMessage(key, fields))
+				continue
+			}
+			checkSpecFields(values[key], field, path+"."+key, result)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			checkSpecFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), result)
+		}
+	}
+}
+
+// specFields maps the YAML keys of a struct, including those of inlined structs, to their field types.
+func specFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for key, inlined := range specFields(field.Type) {
+				fields[key] = inlined
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// unknownFieldMessage reports an unknown key, suggesting the closest known key for likely typos.
+func unknownFieldMessage(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for _, name := range sortedKeys(fields) {
+		if distance := editDistance(key, name); distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("unknown field %s (did you mean %s?)", key, best)
+	}
+	return fmt.Sprintf("unknown field %s (supported: %s)", key, strings.Join(sortedKeys(fields), ", "))
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
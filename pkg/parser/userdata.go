@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserData bootstraps software on a compute at first boot. CloudConfig, File and Scripts
+// each become a part of the cloud-init user data, in that order, and may use the template
+// variables of UserDataVars, e.g. {{ .Service }}.
+type UserData struct {
+	// CloudConfig is an inline cloud-config document; the #cloud-config header is optional.
+	CloudConfig string `yaml:"cloud_config,omitempty"`
+	// File is a cloud-config document or a script starting with #!, read from disk. A relative
+	// path is relative to the manifest, component or overlay that sets it.
+	File string `yaml:"file,omitempty"`
+	// Scripts are shell scripts run in order; scripts without a #! line run with /bin/sh.
+	Scripts []string `yaml:"scripts,omitempty"`
+}
+
+// UserDataVars are the template variables available in user data.
+type UserDataVars struct {
+	Service  string
+	Owner    string
+	Compute  string
+	Provider string
+	VPC      string
+	Subnet   string
+}
+
+// UserDataPart is a rendered part of the user data with its MIME content type.
+type UserDataPart struct {
+	ContentType string
+	Content     string
+}
+
+// Content types of user data parts.
+const (
+	CloudConfigType = "text/cloud-config"
+	ShellScriptType = "text/x-shellscript"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// UserDataVarsFor returns the template variables of a compute.
+func UserDataVarsFor(service *Service, compute Compute) UserDataVars {
+	return UserDataVars{
+		Service:  service.Metadata.Name,
+		Owner:    service.Metadata.Owner,
+		Compute:  compute.Name,
+		Provider: compute.Provider,
+		VPC:      compute.VPC,
+		Subnet:   compute.Subnet,
+	}
+}
+
+// Parts renders the user data into its parts. A nil UserData has none.
+func (u *UserData) Parts(vars UserDataVars) ([]UserDataPart, error) {
+	if u == nil {
+		return nil, nil
+	}
+
+	var parts []UserDataPart
+	if u.CloudConfig != "" {
+		content, err := renderUserData("cloud_config", u.CloudConfig, vars)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(content, cloudConfigHeader) {
+			content = cloudConfigHeader + "\n" + content
+		}
+		parts = append(parts, UserDataPart{ContentType: CloudConfigType, Content: content})
+	}
+
+	if u.File != "" {
+		path, err := ExpandHome(u.File)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user data file: %w", err)
+		}
+		content, err := renderUserData("file", string(data), vars)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasPrefix(content, cloudConfigHeader):
+			parts = append(parts, UserDataPart{ContentType: CloudConfigType, Content: content})
+		case strings.HasPrefix(content, "#!"):
+			parts = append(parts, UserDataPart{ContentType: ShellScriptType, Content: content})
+		default:
+			return nil, fmt.Errorf("user data file %s must start with %s or #!", u.File, cloudConfigHeader)
+		}
+	}
+
+	for i, script := range u.Scripts {
+		content, err := renderUserData(fmt.Sprintf("scripts[%d]", i), script, vars)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(content, "#!") {
+			content = "#!/bin/sh\n" + content
+		}
+		parts = append(parts, UserDataPart{ContentType: ShellScriptType, Content: content})
+	}
+
+	return parts, nil
+}
+
+// resolveUserDataFiles joins the relative user_data.file paths of the computes a manifest,
+// component or overlay document declares to dir, the directory of that document.
+func resolveUserDataFiles(root *yaml.Node, dir string) {
+	computes := mappingValue(mappingValue(mappingValue(root, "spec"), "infrastructure"), "computes")
+	if computes == nil {
+		return
+	}
+	for _, compute := range computes.Content {
+		file := mappingValue(mappingValue(compute, "user_data"), "file")
+		if file == nil || file.Kind != yaml.ScalarNode || file.Value == "" || filepath.IsAbs(file.Value) || strings.HasPrefix(file.Value, "~/") {
+			continue
+		}
+		file.Value = filepath.Join(dir, file.Value)
+	}
+}
+
+// userDataSize returns the combined size in bytes of the rendered parts.
+func userDataSize(parts []UserDataPart) int {
+	size := 0
+	for _, part := range parts {
+		size += len(part.Content)
+	}
+	return size
+}
+
+func renderUserData(name, text string, vars UserDataVars) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	return rendered.String(), nil
+}
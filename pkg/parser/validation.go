@@ -6,6 +6,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"bold/pkg/config"
 )
 
 // ValidationError represents validation errors
@@ -58,6 +60,15 @@ func ValidateService(service *Service) error {
 	// Validate spec
 	validateSpec(service.Spec, result)
 
+	// Decode provider, compute and cluster specs into their typed form
+	decodeSpecs(service, result)
+
+	// Validate references between objects
+	validateReferences(service, result)
+
+	// Validate CIDR containment and overlaps
+	validateAddressSpace(service, result)
+
 	if result.HasErrors() {
 		return result
 	}
@@ -93,13 +104,7 @@ func validateProvider(provider Provider, path string, result *ValidationResult)
 	}
 
 	// Validate provider type
-	validTypes := map[string]bool{
-		"aws":     true,
-		"azurerm": true,
-		"google":  true,
-	}
-
-	if provider.Type != "" && !validTypes[provider.Type] {
+	if _, supported := specTypes[provider.Type]; provider.Type != "" && !supported {
 		result.AddError(path+".type", fmt.Sprintf("unsupported provider type: %s", provider.Type))
 	}
 
@@ -117,33 +122,64 @@ func validateSpec(spec Spec, result *ValidationResult) {
 		validateKeyPair(spec.KeyPair, "spec.key_pair", result)
 	}
 
+	if spec.State.Backend != "" {
+		ValidateStateBackend(spec.State, "spec.state", result)
+	}
+
 	validateInfrastructure(spec.Infrastructure, "spec.infrastructure", result)
 }
 
+// stateBackendRequired lists the config keys each supported state backend needs.
+var stateBackendRequired = map[string][]string{
+	"local":   {},
+	"s3":      {"bucket"},
+	"azurerm": {"storage_account_name", "container_name"},
+	"gcs":     {"bucket"},
+	"http":    {"address"},
+	"pg":      {"conn_str"},
+}
+
+// ValidateStateBackend validates a state backend from the manifest or the config file.
+func ValidateStateBackend(state config.StateBackend, path string, result *ValidationResult) {
+	required, ok := stateBackendRequired[state.Backend]
+	if !ok {
+		result.AddError(path+".backend", fmt.Sprintf("unsupported state backend: %s (supported: local, s3, azurerm, gcs, http, pg)", state.Backend))
+		return
+	}
+
+	for _, key := range required {
+		if value, ok := state.Config[key].(string); !ok || value == "" {
+			result.AddError(fmt.Sprintf("%s.config.%s", path, key), fmt.Sprintf("%s is required for the %s backend", key, state.Backend))
+		}
+	}
+
+	if state.Local && state.Backend != "s3" {
+		result.AddError(path+".local", "local is only supported for the s3 backend")
+	}
+}
+
 func validateKeyPair(keyPair KeyPair, path string, result *ValidationResult) {
 	if keyPair.Name == "" {
 		result.AddError(path+".name", "key pair name is required")
 	}
 
-	if keyPair.PublicKeyFile != "" {
-		filePath := keyPair.PublicKeyFile
+	if !keyPair.UseExisting {
+		// The key pair is generated when the files do not exist yet.
+		return
+	}
 
-		if strings.HasPrefix(filePath, "~/") {
-			homeDir, err := os.UserHomeDir()
-			if err != nil {
-				result.AddError(path+".public_key_file", "cannot determine home directory")
-				return
-			}
-			filePath = strings.Replace(filePath, "~", homeDir, 1)
-		}
+	if keyPair.PublicKeyFile == "" {
+		result.AddError(path+".public_key_file", "public key file is required when use_existing is true")
+		return
+	}
 
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			if keyPair.UseExisting {
-				result.AddError(path+".public_key_file", fmt.Sprintf("existing public key file not found: %s", filePath))
-			} else {
-				result.AddError(path+".public_key_file", fmt.Sprintf("public key file not found: %s", filePath))
-			}
-		}
+	filePath, err := ExpandHome(keyPair.PublicKeyFile)
+	if err != nil {
+		result.AddError(path+".public_key_file", err.Error())
+		return
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		result.AddError(path+".public_key_file", fmt.Sprintf("existing public key file not found: %s", filePath))
 	}
 }
 
@@ -0,0 +1,332 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Variable declares a manifest variable. ${var.<name>} anywhere else in the manifest is
+// replaced by its value, taken from --var, --var-file, BOLT_VAR_<name> or Default.
+type Variable struct {
+	// Type is string, number, bool or list; empty accepts any value.
+	Type        string      `yaml:"type,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+}
+
+// ParseOptions selects the environment overlay of a manifest, supplies the values of its
+// variables and locates the component library. Values are applied in the order defaults, BOLT_VAR_<name> environment
+// variables, VarFiles, Vars; later ones win.
+type ParseOptions struct {
+	// Env selects the overlay overlays/<Env>.yaml next to the manifest; empty uses the base alone.
+	Env string
+	// Vars holds name=value assignments from --var.
+	Vars []string
+	// VarFiles are YAML files mapping variable names to values, from --var-file.
+	VarFiles []string
+	// LibraryDir holds the components imports can name instead of giving a path.
+	LibraryDir string
+}
+
+// variableEnvPrefix prefixes the environment variables that set manifest variables.
+const variableEnvPrefix = "BOLT_VAR_"
+
+var (
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// variableRef matches ${var.name}; a leading $ escapes it as a literal ${var.name}.
+	variableRef = regexp.MustCompile(`\$?\$\{var\.([^}]*)\}`)
+	// parameterRef matches the ${param.name} references of components.
+	parameterRef  = regexp.MustCompile(`\$?\$\{param\.([^}]*)\}`)
+	variableTypes = []string{"string", "number", "bool", "list"}
+)
+
+// resolveVariables replaces the variable references of a manifest document, and of the
+// overlay parts that are merged into it later, with their values. References that take up a
+// whole value keep the type of the variable, so size: ${var.disk_size} stays a number.
+func resolveVariables(document *yaml.Node, opts ParseOptions, overlays ...*yaml.Node) error {
+	result := &ValidationResult{}
+	r := &interpolator{
+		kind:     "variable",
+		block:    "variables",
+		ref:      variableRef,
+		declared: declaredValues(document, "variables", result),
+		invalid:  map[string]bool{},
+		result:   result,
+		hint: func(name string) string {
+			return fmt.Sprintf("set it with --var %s=<value>, a --var-file or %s%s", name, variableEnvPrefix, name)
+		},
+	}
+	r.values = r.variableValues(opts)
+	r.checkValues()
+	r.interpolate(document, "")
+	for _, overlay := range overlays {
+		if overlay != nil {
+			r.interpolate(overlay, "")
+		}
+	}
+
+	if result.HasErrors() {
+		return result
+	}
+	return nil
+}
+
+// declaredValues decodes the block of a document that declares its variables or parameters.
+func declaredValues(document *yaml.Node, block string, result *ValidationResult) map[string]Variable {
+	declared := map[string]Variable{}
+	root := documentRoot(document)
+	if root == nil || root.Kind != yaml.MappingNode {
+		return declared
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != block {
+			continue
+		}
+		if err := root.Content[i+1].Decode(&declared); err != nil {
+			result.AddError(block, fmt.Sprintf("invalid %s block: %v", block, err))
+			return map[string]Variable{}
+		}
+	}
+
+	for _, name := range sortedKeys(declared) {
+		path := block + "." + name
+		if !variableName.MatchString(name) {
+			result.AddError(path, "name must start with a letter or underscore and contain only letters, digits and underscores")
+		}
+		if typ := declared[name].Type; typ != "" && !slices.Contains(variableTypes, typ) {
+			result.AddError(path+".type", fmt.Sprintf("unsupported type: %s (supported: %s)", typ, strings.Join(variableTypes, ", ")))
+		}
+	}
+	return declared
+}
+
+// interpolator replaces the references to the variables of a manifest, or to the parameters
+// of a component, with their values.
+type interpolator struct {
+	// kind names the values in errors: variable or parameter.
+	kind string
+	// block is the top-level key that declares the values; it is not interpolated.
+	block    string
+	ref      *regexp.Regexp
+	declared map[string]Variable
+	values   map[string]interface{}
+	// invalid marks variables whose value has already been reported as having the wrong type.
+	invalid map[string]bool
+	result  *ValidationResult
+	// hint tells how to set a value that is missing.
+	hint func(name string) string
+}
+
+// variableValues collects the value of each declared variable from its sources.
+func (r *interpolator) variableValues(opts ParseOptions) map[string]interface{} {
+	values := map[string]interface{}{}
+	for name, variable := range r.declared {
+		if variable.Default != nil {
+			values[name] = variable.Default
+		}
+	}
+
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		name, ok := strings.CutPrefix(key, variableEnvPrefix)
+		// The environment is shared with other tools, so unknown names are ignored.
+		if _, declared := r.declared[name]; ok && declared {
+			values[name] = r.fromString(name, value)
+		}
+	}
+
+	for _, file := range opts.VarFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			r.result.AddError(file, fmt.Sprintf("failed to read variable file: %v", err))
+			continue
+		}
+		var fileValues map[string]interface{}
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			r.result.AddError(file, fmt.Sprintf("failed to parse variable file: %v", err))
+			continue
+		}
+		for _, name := range sortedKeys(fileValues) {
+			if _, declared := r.declared[name]; !declared {
+				r.result.AddError(file, fmt.Sprintf("undeclared variable %s", name))
+				continue
+			}
+			values[name] = fileValues[name]
+		}
+	}
+
+	for _, assignment := range opts.Vars {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			r.result.AddError("--var", fmt.Sprintf("%q must have the form name=value", assignment))
+			continue
+		}
+		if _, declared := r.declared[name]; !declared {
+			r.result.AddError("--var", fmt.Sprintf("undeclared variable %s", name))
+			continue
+		}
+		values[name] = r.fromString(name, value)
+	}
+	return values
+}
+
+// checkValues converts the values to the declared types, reporting and dropping those that
+// do not match.
+func (r *interpolator) checkValues() {
+	for _, name := range sortedKeys(r.values) {
+		value, err := convertVariable(r.declared[name].Type, r.values[name])
+		if err != nil {
+			r.result.AddError(r.block+"."+name, err.Error())
+			r.invalid[name] = true
+			delete(r.values, name)
+			continue
+		}
+		r.values[name] = value
+	}
+}
+
+// fromString parses a value given on the command line or in the environment. Values of
+// typed variables are read as YAML, so 3, true and [a, b] become a number, a bool and a list.
+func (r *interpolator) fromString(name, value string) interface{} {
+	switch r.declared[name].Type {
+	case "", "string":
+		return value
+	}
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
+
+// convertVariable checks a value against the variable type. Numbers and bools are accepted
+// for string variables and converted.
+func convertVariable(typ string, value interface{}) (interface{}, error) {
+	ok := true
+	switch typ {
+	case "string":
+		switch v := value.(type) {
+		case string:
+		case int, float64, bool:
+			value = fmt.Sprint(v)
+		default:
+			ok = false
+		}
+	case "number":
+		switch value.(type) {
+		case int, float64:
+		default:
+			ok = false
+		}
+	case "bool":
+		_, ok = value.(bool)
+	case "list":
+		_, ok = value.([]interface{})
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("value %v is not a %s", value, typ)
+	}
+	return value, nil
+}
+
+// interpolate replaces the variable references below node; path is the field path of node
+// in the form used by validation errors.
+func (r *interpolator) interpolate(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			r.interpolate(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path == "" && key == r.block {
+				continue
+			}
+			r.interpolate(node.Content[i+1], joinFieldPath(path, key))
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			r.interpolate(child, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.ScalarNode:
+		r.interpolateScalar(node, path)
+	}
+}
+
+func (r *interpolator) interpolateScalar(node *yaml.Node, path string) {
+	matches := r.ref.FindAllStringSubmatchIndex(node.Value, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(node.Value) && !strings.HasPrefix(node.Value, "$$") {
+		value, ok := r.lookup(node, path, node.Value[matches[0][2]:matches[0][3]])
+		if !ok {
+			return
+		}
+		var replacement yaml.Node
+		if err := replacement.Encode(value); err != nil {
+			r.result.AddError(path, err.Error())
+			return
+		}
+		replacement.Line, replacement.Column = node.Line, node.Column
+		*node = replacement
+		return
+	}
+
+	var text strings.Builder
+	last := 0
+	for _, match := range matches {
+		text.WriteString(node.Value[last:match[0]])
+		last = match[1]
+
+		ref := node.Value[match[0]:match[1]]
+		if strings.HasPrefix(ref, "$$") {
+			text.WriteString(ref[1:])
+			continue
+		}
+		value, ok := r.lookup(node, path, node.Value[match[2]:match[3]])
+		if !ok {
+			text.WriteString(ref)
+			continue
+		}
+		if _, isList := value.([]interface{}); isList {
+			r.result.AddError(path, fmt.Sprintf("list %s %s cannot be part of a string (line %d, column %d)", r.kind, node.Value[match[2]:match[3]], node.Line, node.Column))
+			continue
+		}
+		text.WriteString(fmt.Sprint(value))
+	}
+	text.WriteString(node.Value[last:])
+
+	node.Value = text.String()
+	// Let YAML resolve the type of the result again, unless the value was quoted or tagged.
+	if node.Style&yaml.TaggedStyle == 0 {
+		node.Tag = ""
+	}
+}
+
+// lookup returns the value of a reference, reporting undefined names and values that are
+// missing at the reference.
+func (r *interpolator) lookup(node *yaml.Node, path, name string) (interface{}, bool) {
+	if _, declared := r.declared[name]; !declared {
+		r.result.AddError(path, fmt.Sprintf("undefined %s %s (line %d, column %d)", r.kind, name, node.Line, node.Column))
+		return nil, false
+	}
+	if r.invalid[name] {
+		return nil, false
+	}
+	value, ok := r.values[name]
+	if !ok {
+		r.result.AddError(path, fmt.Sprintf("%s %s has no value (line %d, column %d); %s", r.kind, name, node.Line, node.Column, r.hint(name)))
+		return nil, false
+	}
+	return value, true
+}
@@ -0,0 +1,309 @@
+package plan
+
+import (
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResourceChange is a single OpenTofu resource change attributed to a manifest resource.
+type ResourceChange struct {
+	Address string
+	Type    string
+	Name    string
+	Action  string
+}
+
+// ResourceSummary groups the OpenTofu changes that belong to one manifest resource.
+type ResourceSummary struct {
+	Kind    string
+	Name    string
+	Counts  map[string]int
+	Changes []ResourceChange
+}
+
+// Summary is the Bolt-level view of an OpenTofu plan.
+type Summary struct {
+	Service   string
+	Resources []ResourceSummary
+	Totals    map[string]int
+}
+
+// Change actions reported in the summary.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionReplace = "replace"
+	ActionDelete  = "delete"
+)
+
+// Resource kinds, in the order they are printed.
+var kindOrder = []string{"network", "subnet", "peering", "security_group", "compute", "kubernetes", "other"}
+
+// resourceKind maps a generated OpenTofu resource type to the manifest kind that produced it,
+// using the resource kinds declared by the registered provider backends.
+func resourceKind(resourceType string) string {
+	for _, providerType := range provider.Types() {
+		backend, _ := provider.Lookup(providerType)
+		if kind, ok := backend.Info().ResourceKinds[resourceType]; ok {
+			return kind
+		}
+	}
+	return "other"
+}
+
+// tofuPlan is the subset of `tofu show -json` output used by the summary.
+type tofuPlan struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Mode    string `json:"mode"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// Summarize converts the JSON representation of an OpenTofu plan into a
+// summary grouped by the manifest resources that produced each change.
+func Summarize(service *parser.Service, planJSON []byte) (*Summary, error) {
+	var tp tofuPlan
+	if err := json.Unmarshal(planJSON, &tp); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+
+	names := manifestNames(service)
+	summary := &Summary{
+		Service: service.Metadata.Name,
+		Totals:  make(map[string]int),
+	}
+	index := make(map[string]int)
+
+	for _, rc := range tp.ResourceChanges {
+		if rc.Mode == "data" {
+			continue
+		}
+
+		action := normalizeAction(rc.Change.Actions)
+		if action == "" {
+			continue
+		}
+
+		kind := resourceKind(rc.Type)
+		owner := resolveOwner(rc.Name, names[kind])
+
+		key := kind + "/" + owner
+		i, exists := index[key]
+		if !exists {
+			summary.Resources = append(summary.Resources, ResourceSummary{
+				Kind:   kind,
+				Name:   owner,
+				Counts: make(map[string]int),
+			})
+			i = len(summary.Resources) - 1
+			index[key] = i
+		}
+
+		summary.Resources[i].Counts[action]++
+		summary.Resources[i].Changes = append(summary.Resources[i].Changes, ResourceChange{
+			Address: rc.Address,
+			Type:    rc.Type,
+			Name:    rc.Name,
+			Action:  action,
+		})
+		summary.Totals[action]++
+	}
+
+	sort.SliceStable(summary.Resources, func(a, b int) bool {
+		ka, kb := kindRank(summary.Resources[a].Kind), kindRank(summary.Resources[b].Kind)
+		if ka != kb {
+			return ka < kb
+		}
+		return summary.Resources[a].Name < summary.Resources[b].Name
+	})
+
+	return summary, nil
+}
+
+// HasChanges reports whether the plan contains any create, update, replace or delete.
+func (s *Summary) HasChanges() bool {
+	return len(s.Resources) > 0
+}
+
+// normalizeAction collapses an OpenTofu action list into a single summary action.
+// No-op and read actions return an empty string.
+func normalizeAction(actions []string) string {
+	hasCreate, hasDelete := false, false
+	for _, action := range actions {
+		switch action {
+		case "create":
+			hasCreate = true
+		case "delete":
+			hasDelete = true
+		case "update":
+			return ActionUpdate
+		}
+	}
+
+	switch {
+	case hasCreate && hasDelete:
+		return ActionReplace
+	case hasCreate:
+		return ActionCreate
+	case hasDelete:
+		return ActionDelete
+	}
+	return ""
+}
+
+// manifestNames collects manifest resource names per kind.
+func manifestNames(service *parser.Service) map[string][]string {
+	names := make(map[string][]string)
+	infra := service.Spec.Infrastructure
+
+	for _, network := range infra.Networks {
+		names["network"] = append(names["network"], network.Name)
+		for _, subnet := range network.Subnets {
+			names["subnet"] = append(names["subnet"], subnet.Name)
+		}
+	}
+	for _, peering := range infra.Peerings {
+		names["peering"] = append(names["peering"], peering.Name)
+	}
+	for _, sg := range infra.SecurityGroups {
+		names["security_group"] = append(names["security_group"], sg.Name)
+	}
+	for _, compute := range infra.Computes {
+		names["compute"] = append(names["compute"], compute.Name)
+	}
+	for _, cluster := range infra.KubernetesClusters {
+		names["kubernetes"] = append(names["kubernetes"], cluster.Name)
+	}
+
+	return names
+}
+
+// resolveOwner finds the manifest resource that generated an OpenTofu resource.
+// Generated names are either the manifest name itself or the manifest name
+// followed by a suffix such as "-nic" or "-rule-0"; the longest match wins.
+func resolveOwner(resourceName string, candidates []string) string {
+	owner := ""
+	for _, candidate := range candidates {
+		if resourceName == candidate {
+			return candidate
+		}
+		if strings.HasPrefix(resourceName, candidate) && len(candidate) > len(owner) {
+			next := resourceName[len(candidate)]
+			if next == '-' || next == '_' {
+				owner = candidate
+			}
+		}
+	}
+	if owner == "" {
+		return resourceName
+	}
+	return owner
+}
+
+func kindRank(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
+
+// FormatSummary renders the plan summary for human review.
+func FormatSummary(summary *Summary) string {
+	var output strings.Builder
+
+	output.WriteString("📝 Bolt Plan Summary\n")
+	output.WriteString("====================\n\n")
+	output.WriteString(fmt.Sprintf("Service: %s\n\n", summary.Service))
+
+	if !summary.HasChanges() {
+		output.WriteString("No changes. Infrastructure matches the manifest.\n")
+		return output.String()
+	}
+
+	currentKind := ""
+	for _, resource := range summary.Resources {
+		if resource.Kind != currentKind {
+			if currentKind != "" {
+				output.WriteString("\n")
+			}
+			currentKind = resource.Kind
+			output.WriteString(fmt.Sprintf("%s:\n", kindTitle(resource.Kind)))
+		}
+
+		output.WriteString(fmt.Sprintf("  %s %s (%s)\n", actionSymbol(dominantAction(resource.Counts)), resource.Name, formatCounts(resource.Counts)))
+		for _, change := range resource.Changes {
+			output.WriteString(fmt.Sprintf("      %s %s\n", actionSymbol(change.Action), change.Address))
+		}
+	}
+
+	output.WriteString("\n")
+	output.WriteString(fmt.Sprintf("Plan: %d to create, %d to update, %d to replace, %d to delete.\n",
+		summary.Totals[ActionCreate], summary.Totals[ActionUpdate],
+		summary.Totals[ActionReplace], summary.Totals[ActionDelete]))
+
+	return output.String()
+}
+
+func kindTitle(kind string) string {
+	switch kind {
+	case "network":
+		return "Networks"
+	case "subnet":
+		return "Subnets"
+	case "peering":
+		return "Peerings"
+	case "security_group":
+		return "Security Groups"
+	case "compute":
+		return "Computes"
+	case "kubernetes":
+		return "Kubernetes Clusters"
+	default:
+		return "Other Resources"
+	}
+}
+
+// dominantAction picks the most disruptive action for a manifest resource.
+func dominantAction(counts map[string]int) string {
+	for _, action := range []string{ActionDelete, ActionReplace, ActionUpdate, ActionCreate} {
+		if counts[action] > 0 {
+			return action
+		}
+	}
+	return ""
+}
+
+func formatCounts(counts map[string]int) string {
+	var parts []string
+	for _, action := range []string{ActionCreate, ActionUpdate, ActionReplace, ActionDelete} {
+		if counts[action] > 0 {
+			parts = append(parts, fmt.Sprintf("%d to %s", counts[action], action))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func actionSymbol(action string) string {
+	switch action {
+	case ActionCreate:
+		return "+"
+	case ActionUpdate:
+		return "~"
+	case ActionReplace:
+		return "-/+"
+	case ActionDelete:
+		return "-"
+	}
+	return " "
+}
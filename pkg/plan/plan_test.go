@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+
+	"bold/pkg/parser"
+	_ "bold/pkg/provider/builtin"
+)
+
+func TestSummarize(t *testing.T) {
+	service := &parser.Service{
+		Metadata: parser.Metadata{Name: "test-service"},
+		Spec: parser.Spec{
+			Infrastructure: parser.Infrastructure{
+				Networks: []parser.Network{
+					{
+						Name:    "vnet-main",
+						Subnets: []parser.Subnet{{Name: "subnet-public"}},
+					},
+				},
+				SecurityGroups: []parser.SecurityGroup{{Name: "nsg-web"}},
+				Computes:       []parser.Compute{{Name: "web-vm"}},
+			},
+		},
+	}
+
+	planJSON := `{
+  "resource_changes": [
+    {"address": "azurerm_virtual_network.vnet-main", "type": "azurerm_virtual_network", "name": "vnet-main", "change": {"actions": ["no-op"]}},
+    {"address": "azurerm_subnet.subnet-public", "type": "azurerm_subnet", "name": "subnet-public", "change": {"actions": ["update"]}},
+    {"address": "azurerm_network_security_group.nsg-web", "type": "azurerm_network_security_group", "name": "nsg-web", "change": {"actions": ["create"]}},
+    {"address": "azurerm_network_security_rule.nsg-web-rule-0", "type": "azurerm_network_security_rule", "name": "nsg-web-rule-0", "change": {"actions": ["create"]}},
+    {"address": "azurerm_linux_virtual_machine.web-vm", "type": "azurerm_linux_virtual_machine", "name": "web-vm", "change": {"actions": ["delete", "create"]}},
+    {"address": "azurerm_network_interface.web-vm-nic", "type": "azurerm_network_interface", "name": "web-vm-nic", "change": {"actions": ["delete"]}}
+  ]
+}`
+
+	summary, err := Summarize(service, []byte(planJSON))
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	if len(summary.Resources) != 3 {
+		t.Fatalf("Expected 3 manifest resources with changes, got %d", len(summary.Resources))
+	}
+
+	expected := []struct {
+		kind   string
+		name   string
+		counts map[string]int
+	}{
+		{"subnet", "subnet-public", map[string]int{ActionUpdate: 1}},
+		{"security_group", "nsg-web", map[string]int{ActionCreate: 2}},
+		{"compute", "web-vm", map[string]int{ActionReplace: 1, ActionDelete: 1}},
+	}
+
+	for i, want := range expected {
+		got := summary.Resources[i]
+		if got.Kind != want.kind || got.Name != want.name {
+			t.Errorf("Resource %d: expected %s/%s, got %s/%s", i, want.kind, want.name, got.Kind, got.Name)
+		}
+		for action, count := range want.counts {
+			if got.Counts[action] != count {
+				t.Errorf("Resource %s: expected %d to %s, got %d", got.Name, count, action, got.Counts[action])
+			}
+		}
+	}
+
+	output := FormatSummary(summary)
+	if !strings.Contains(output, "Plan: 2 to create, 1 to update, 1 to replace, 1 to delete.") {
+		t.Errorf("Unexpected totals in summary:\n%s", output)
+	}
+}
+
+func TestNormalizeAction(t *testing.T) {
+	tests := []struct {
+		actions []string
+		want    string
+	}{
+		{[]string{"no-op"}, ""},
+		{[]string{"read"}, ""},
+		{[]string{"create"}, ActionCreate},
+		{[]string{"update"}, ActionUpdate},
+		{[]string{"delete"}, ActionDelete},
+		{[]string{"delete", "create"}, ActionReplace},
+		{[]string{"create", "delete"}, ActionReplace},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeAction(tt.actions); got != tt.want {
+			t.Errorf("normalizeAction(%v) = %q, want %q", tt.actions, got, tt.want)
+		}
+	}
+}
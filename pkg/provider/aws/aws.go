@@ -0,0 +1,448 @@
+// Package aws compiles manifest resources for Amazon Web Services (and LocalStack).
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"bold/pkg/catalog"
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+func init() {
+	provider.Register(&Backend{})
+}
+
+// Backend is the provider.Backend for the "aws" provider type.
+type Backend struct{}
+
+func (b *Backend) Info() provider.Info {
+	return provider.Info{
+		Type:        "aws",
+		DisplayName: "AWS",
+		ClusterType: "eks",
+		Specs:       specTypes,
+		ResourceKinds: map[string]string{
+			"aws_vpc":                             "network",
+			"aws_subnet":                          "subnet",
+			"aws_vpc_peering_connection":          "peering",
+			"aws_vpc_peering_connection_accepter": "peering",
+			"aws_route":                           "peering",
+			"aws_security_group":                  "security_group",
+			"aws_instance":                        "compute",
+			"aws_ebs_volume":                      "compute",
+			"aws_volume_attachment":               "compute",
+			"aws_eks_cluster":                     "kubernetes",
+			"aws_eks_node_group":                  "kubernetes",
+			"aws_iam_role":                        "kubernetes",
+			"aws_iam_role_policy_attachment":      "kubernetes",
+		},
+	}
+}
+
+func (b *Backend) RequiredProviders() map[string]interface{} {
+	return map[string]interface{}{
+		"aws": map[string]interface{}{
+			"source":  "hashicorp/aws",
+			"version": "~> 5.0",
+		},
+	}
+}
+
+func (b *Backend) ProviderConfig(ctx *provider.Context) map[string]interface{} {
+	config := map[string]interface{}{}
+
+	config["region"] = region(ctx)
+
+	if provider.IsLocal(ctx.Provider) {
+		config["access_key"] = "test"
+		config["secret_key"] = "test"
+		config["s3_use_path_style"] = true
+		config["skip_credentials_validation"] = true
+		config["skip_requesting_account_id"] = true
+		config["skip_metadata_api_check"] = true
+		config["endpoints"] = localEndpoints(ctx.Config.Providers.AWS.LocalStackURL)
+	}
+
+	return config
+}
+
+func (b *Backend) Networks(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, network := range service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			vpcName := network.Name
+			resources.Add("aws_vpc", vpcName, map[string]interface{}{
+				"cidr_block": network.CIDR,
+				"tags":       provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": vpcName}),
+			})
+		}
+	}
+}
+
+func (b *Backend) Subnets(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, network := range service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			for _, subnet := range network.Subnets {
+				subnetName := subnet.Name
+				resources.Add("aws_subnet", subnetName, map[string]interface{}{
+					"vpc_id":            fmt.Sprintf("${aws_vpc.%s.id}", network.Name),
+					"cidr_block":        subnet.CIDR,
+					"availability_zone": subnet.Zone,
+					"tags":              provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": subnetName}),
+				})
+			}
+		}
+	}
+}
+
+func (b *Backend) Peerings(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, peering := range service.Spec.Infrastructure.Peerings {
+		if peering.Provider == ctx.Provider.Name {
+			peeringName := peering.Name
+			resources.Add("aws_vpc_peering_connection", peeringName, map[string]interface{}{
+				"vpc_id":      fmt.Sprintf("${aws_vpc.%s.id}", peering.VPCRequester),
+				"peer_vpc_id": fmt.Sprintf("${aws_vpc.%s.id}", peering.VPCAccepter),
+				"tags":        provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": peeringName, "Side": "Requester"}),
+			})
+
+			resources.Add("aws_vpc_peering_connection_accepter", peeringName, map[string]interface{}{
+				"vpc_peering_connection_id": fmt.Sprintf("${aws_vpc_peering_connection.%s.id}", peeringName),
+				"auto_accept":               true,
+				"tags":                      provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": peeringName, "Side": "Accepter"}),
+			})
+
+			if accepter := provider.FindNetwork(service, peering.VPCAccepter); accepter != nil {
+				resources.Add("aws_route", peeringName+"-requester", map[string]interface{}{
+					"route_table_id":            fmt.Sprintf("${aws_vpc.%s.main_route_table_id}", peering.VPCRequester),
+					"destination_cidr_block":    accepter.CIDR,
+					"vpc_peering_connection_id": fmt.Sprintf("${aws_vpc_peering_connection_accepter.%s.id}", peeringName),
+				})
+			}
+			if requester := provider.FindNetwork(service, peering.VPCRequester); requester != nil {
+				resources.Add("aws_route", peeringName+"-accepter", map[string]interface{}{
+					"route_table_id":            fmt.Sprintf("${aws_vpc.%s.main_route_table_id}", peering.VPCAccepter),
+					"destination_cidr_block":    requester.CIDR,
+					"vpc_peering_connection_id": fmt.Sprintf("${aws_vpc_peering_connection_accepter.%s.id}", peeringName),
+				})
+			}
+		}
+	}
+}
+
+func (b *Backend) SecurityGroups(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, sg := range service.Spec.Infrastructure.SecurityGroups {
+		if sg.Provider == ctx.Provider.Name {
+			sgName := sg.Name
+			sgConfig := map[string]interface{}{
+				"name":        sgName,
+				"description": fmt.Sprintf("Security group for %s", sgName),
+				"vpc_id":      fmt.Sprintf("${aws_vpc.%s.id}", sg.VPC),
+				"tags":        provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": sgName}),
+			}
+
+			var ingressRules []map[string]interface{}
+			var egressRules []map[string]interface{}
+
+			for _, rule := range sg.Rules {
+				ruleConfig := map[string]interface{}{
+					"protocol":         rule.Protocol,
+					"from_port":        rule.FromPort,
+					"to_port":          rule.ToPort,
+					"description":      "",
+					"ipv6_cidr_blocks": []string{},
+					"prefix_list_ids":  []string{},
+					"security_groups":  []string{},
+					"self":             false,
+				}
+
+				if cidrs := provider.RuleCIDRBlocks(service, rule); len(cidrs) > 0 {
+					ruleConfig["cidr_blocks"] = cidrs
+				} else {
+					ruleConfig["cidr_blocks"] = []string{"0.0.0.0/0"}
+				}
+
+				if rule.Type == "ingress" {
+					ingressRules = append(ingressRules, ruleConfig)
+				} else if rule.Type == "egress" {
+					egressRules = append(egressRules, ruleConfig)
+				}
+			}
+
+			if len(ingressRules) > 0 {
+				sgConfig["ingress"] = ingressRules
+			}
+			if len(egressRules) > 0 {
+				sgConfig["egress"] = egressRules
+			}
+
+			resources.Add("aws_security_group", sgName, sgConfig)
+		}
+	}
+}
+
+func (b *Backend) Computes(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	if ctx.PublicKey != "" {
+		keyPairResourceName := strings.ReplaceAll(service.Spec.KeyPair.Name, " ", "_")
+		resources.Add("aws_key_pair", keyPairResourceName, map[string]interface{}{
+			"key_name":   service.Spec.KeyPair.Name,
+			"public_key": ctx.PublicKey,
+			"tags":       provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": service.Spec.KeyPair.Name}),
+		})
+	}
+
+	for _, compute := range service.Spec.Infrastructure.Computes {
+		if compute.Provider == ctx.Provider.Name && compute.Type == "ec2" {
+			vmName := compute.Name
+			spec := computeSpecOf(compute)
+			instance := map[string]interface{}{
+				"ami":           ami(ctx, compute),
+				"instance_type": spec.InstanceSize(),
+				"subnet_id":     fmt.Sprintf("${aws_subnet.%s.id}", compute.Subnet),
+				"tags":          provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": vmName}),
+			}
+
+			if rootDiskSize := spec.RootDiskSizeGB(); rootDiskSize > 0 {
+				instance["root_block_device"] = map[string]interface{}{
+					"volume_size": rootDiskSize,
+				}
+			}
+
+			if ctx.PublicKey != "" {
+				instance["key_name"] = fmt.Sprintf("${aws_key_pair.%s.key_name}", strings.ReplaceAll(service.Spec.KeyPair.Name, " ", "_"))
+			}
+
+			if compute.SecurityGroup != "" {
+				instance["vpc_security_group_ids"] = []string{fmt.Sprintf("${aws_security_group.%s.id}", compute.SecurityGroup)}
+			}
+
+			for i, storage := range compute.Storage {
+				volumeName := fmt.Sprintf("%s-%s", vmName, storage.Name)
+				// The zone comes from the subnet rather than the instance so that the
+				// instance's user_data can reference the volume ID without a cycle.
+				resources.Add("aws_ebs_volume", volumeName, map[string]interface{}{
+					"availability_zone": fmt.Sprintf("${aws_subnet.%s.availability_zone}", compute.Subnet),
+					"size":              storage.Size,
+					"type":              storage.Type,
+					"encrypted":         storage.Encrypted,
+					"tags":              provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": volumeName}),
+				})
+
+				resources.Add("aws_volume_attachment", volumeName, map[string]interface{}{
+					"device_name": deviceName(i),
+					"volume_id":   fmt.Sprintf("${aws_ebs_volume.%s.id}", volumeName),
+					"instance_id": fmt.Sprintf("${aws_instance.%s.id}", vmName),
+				})
+			}
+			if userData := renderUserData(service, compute); userData != "" {
+				instance["user_data"] = userData
+			}
+
+			resources.Add("aws_instance", vmName, instance)
+		}
+	}
+}
+
+func (b *Backend) Cluster(ctx *provider.Context, cluster parser.KubernetesCluster, resources provider.Resources) {
+	service := ctx.Service
+	clusterName := cluster.Name
+
+	spec := clusterSpecOf(cluster)
+	version := spec.Version
+	if version == "" {
+		version = "1.28"
+	}
+	nodes := spec.NodePools()[0]
+	nodeDiskSize := spec.NodeDiskSizeGB
+	if nodeDiskSize == 0 {
+		nodeDiskSize = 20
+	}
+
+	var subnetIDs []string
+	for _, subnet := range provider.ClusterSubnets(service, cluster) {
+		subnetIDs = append(subnetIDs, fmt.Sprintf("${aws_subnet.%s.id}", subnet))
+	}
+
+	clusterRole := clusterName + "_cluster_role"
+	resources.Add("aws_iam_role", clusterRole, map[string]interface{}{
+		"name":               clusterName + "-cluster-role",
+		"assume_role_policy": assumeRolePolicy("eks.amazonaws.com"),
+		"tags":               provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": clusterName + "-cluster-role"}),
+	})
+	clusterDependencies := attachPolicies(resources, clusterName, clusterRole, []policyAttachment{
+		{Name: "cluster_policy", Policy: "AmazonEKSClusterPolicy"},
+		{Name: "vpc_resource_controller", Policy: "AmazonEKSVPCResourceController"},
+	})
+
+	nodeRole := clusterName + "_node_role"
+	resources.Add("aws_iam_role", nodeRole, map[string]interface{}{
+		"name":               clusterName + "-node-role",
+		"assume_role_policy": assumeRolePolicy("ec2.amazonaws.com"),
+		"tags":               provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": clusterName + "-node-role"}),
+	})
+	nodeDependencies := attachPolicies(resources, clusterName, nodeRole, []policyAttachment{
+		{Name: "worker_node_policy", Policy: "AmazonEKSWorkerNodePolicy"},
+		{Name: "cni_policy", Policy: "AmazonEKS_CNI_Policy"},
+		{Name: "ecr_read_only", Policy: "AmazonEC2ContainerRegistryReadOnly"},
+	})
+
+	resources.Add("aws_eks_cluster", clusterName, map[string]interface{}{
+		"name":     clusterName,
+		"role_arn": fmt.Sprintf("${aws_iam_role.%s.arn}", clusterRole),
+		"version":  version,
+		"vpc_config": map[string]interface{}{
+			"subnet_ids":              subnetIDs,
+			"endpoint_private_access": true,
+			"endpoint_public_access":  true,
+		},
+		"depends_on": clusterDependencies,
+		"tags":       provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": clusterName}),
+	})
+
+	resources.Add("aws_eks_node_group", clusterName, map[string]interface{}{
+		"cluster_name":    fmt.Sprintf("${aws_eks_cluster.%s.name}", clusterName),
+		"node_group_name": fmt.Sprintf("%s-nodes", clusterName),
+		"node_role_arn":   fmt.Sprintf("${aws_iam_role.%s.arn}", nodeRole),
+		"subnet_ids":      subnetIDs,
+		"instance_types":  []string{nodes.Size},
+		"scaling_config": map[string]interface{}{
+			"desired_size": nodes.Count,
+			"max_size":     nodes.Count,
+			"min_size":     1,
+		},
+		"disk_size":  nodeDiskSize,
+		"depends_on": nodeDependencies,
+		"tags":       provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": fmt.Sprintf("%s-nodes", clusterName)}),
+	})
+}
+
+// policyAttachment attaches an AWS managed policy to a role.
+type policyAttachment struct {
+	Name   string
+	Policy string
+}
+
+// attachPolicies adds an aws_iam_role_policy_attachment named <cluster>_<attachment> for every
+// attachment and returns their addresses for depends_on.
+func attachPolicies(resources provider.Resources, clusterName, role string, attachments []policyAttachment) []string {
+	var addresses []string
+	for _, attachment := range attachments {
+		name := fmt.Sprintf("%s_%s", clusterName, attachment.Name)
+		resources.Add("aws_iam_role_policy_attachment", name, map[string]interface{}{
+			"role":       fmt.Sprintf("${aws_iam_role.%s.name}", role),
+			"policy_arn": "arn:aws:iam::aws:policy/" + attachment.Policy,
+		})
+		addresses = append(addresses, "aws_iam_role_policy_attachment."+name)
+	}
+	return addresses
+}
+
+// assumeRolePolicy returns the trust policy that lets an AWS service assume a role.
+func assumeRolePolicy(service string) string {
+	policy := map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": service},
+			"Action":    "sts:AssumeRole",
+		}},
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+// ami returns the AMI of an instance: the image id, or an aws_ami lookup of the catalog
+// image that is shared by all instances with the same image. LocalStack does not serve
+// the public AMIs, so local providers keep a placeholder instead.
+func ami(ctx *provider.Context, compute parser.Compute) string {
+	if compute.Image != nil && compute.Image.ID != "" {
+		return compute.Image.ID
+	}
+	if provider.IsLocal(ctx.Provider) {
+		return "ami-local"
+	}
+
+	// Images the catalog does not know are rejected by parser.DecodeSpecs.
+	image, _ := catalog.NormalizeImage(compute.Image.OSImage())
+	lookup, _ := catalog.LookupAWSImage(image)
+	name := strings.ReplaceAll(fmt.Sprintf("%s-%s-%s", image.OS, image.Version, image.Arch), ".", "_")
+	ctx.DataSources.Add("aws_ami", name, map[string]interface{}{
+		"most_recent": true,
+		"owners":      []string{lookup.Owner},
+		"filter": []map[string]interface{}{
+			{"name": "name", "values": []string{lookup.Name}},
+			{"name": "architecture", "values": []string{lookup.Architecture}},
+			{"name": "virtualization-type", "values": []string{"hvm"}},
+		},
+	})
+	return fmt.Sprintf("${data.aws_ami.%s.id}", name)
+}
+
+// deviceName returns the device name used to attach the i-th EBS data volume (/dev/sdf, /dev/sdg, ...).
+// MaxVolumes in specTypes keeps index within /dev/sdz.
+func deviceName(index int) string {
+	return fmt.Sprintf("/dev/sd%c", 'f'+index)
+}
+
+// volumeMounts returns the EBS volumes of an instance that are mounted at a path.
+func volumeMounts(compute parser.Compute) []provider.VolumeMount {
+	var mounts []provider.VolumeMount
+	for i, storage := range compute.Storage {
+		if storage.Path == "" {
+			continue
+		}
+		volumeName := fmt.Sprintf("%s-%s", compute.Name, storage.Name)
+		mounts = append(mounts, provider.VolumeMount{
+			Path: storage.Path,
+			Devices: []string{
+				// Nitro instances expose EBS volumes as NVMe devices named after the volume ID.
+				fmt.Sprintf("/dev/disk/by-id/nvme-Amazon_Elastic_Block_Store_${replace(aws_ebs_volume.%s.id, \"-\", \"\")}", volumeName),
+				strings.Replace(deviceName(i), "/dev/sd", "/dev/xvd", 1),
+			},
+		})
+	}
+	return mounts
+}
+
+// renderUserData renders the user data of an instance, which also mounts its volumes.
+func renderUserData(service *parser.Service, compute parser.Compute) string {
+	return provider.UserData(service, compute, volumeMounts(compute))
+}
+
+// localStackServices are the services whose endpoints point at LocalStack in local mode.
+var localStackServices = []string{
+	"acm", "apigateway", "autoscaling", "cloudformation", "cloudwatch", "cloudwatchlogs",
+	"dynamodb", "ec2", "ecr", "ecs", "eks", "elb", "elbv2", "events", "iam", "kinesis",
+	"kms", "lambda", "rds", "route53", "s3", "secretsmanager", "sns", "sqs", "ssm", "sts",
+}
+
+// localEndpoints maps every LocalStack service to the configured LocalStack URL.
+func localEndpoints(url string) map[string]string {
+	endpoints := make(map[string]string, len(localStackServices))
+	for _, service := range localStackServices {
+		endpoints[service] = url
+	}
+	return endpoints
+}
+
+// region returns the provider spec region, then providers.aws.default_region, then defaults.region.
+func region(ctx *provider.Context) string {
+	if region := specOf(ctx).Region; region != "" {
+		return region
+	}
+	if region := ctx.Config.Providers.AWS.DefaultRegion; region != "" {
+		return region
+	}
+	return ctx.Config.Defaults.Region
+}
@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+// providerSpec is the typed spec of an "aws" provider.
+type providerSpec struct {
+	parser.ProviderSettings `yaml:",inline"`
+}
+
+// ec2Spec is the typed spec of an "ec2" compute.
+type ec2Spec struct {
+	parser.ComputeSizing `yaml:",inline"`
+	InstanceType         string `yaml:"instance_type,omitempty"`
+	RootDiskSize         int    `yaml:"root_disk_size_gb,omitempty"`
+}
+
+func (s *ec2Spec) InstanceSize() string {
+	return provider.InstanceSize("aws", s.InstanceType, s.ComputeSizing, "t2.micro")
+}
+
+func (s *ec2Spec) RootDiskSizeGB() int {
+	return s.RootDiskSize
+}
+
+// eksSpec is the typed spec of an EKS cluster.
+type eksSpec struct {
+	Version        string   `yaml:"version,omitempty"`
+	NodeType       string   `yaml:"node_type,omitempty"`
+	NodeCount      int      `yaml:"node_count,omitempty"`
+	NodeDiskSizeGB int      `yaml:"node_disk_size_gb,omitempty"`
+	Subnets        []string `yaml:"subnets,omitempty"`
+}
+
+func (s *eksSpec) NodePools() []parser.NodePool {
+	return provider.NodePools(parser.NodePool{Size: s.NodeType, Count: s.NodeCount}, "t3.medium", nil)
+}
+
+func (s *eksSpec) SubnetNames() []string {
+	return s.Subnets
+}
+
+var specTypes = parser.SpecTypes{
+	Provider: func() parser.ProviderSpec { return &providerSpec{} },
+	Cluster:  func() parser.ClusterSpec { return &eksSpec{} },
+	Computes: map[string]func() parser.ComputeSpec{
+		"ec2": func() parser.ComputeSpec { return &ec2Spec{} },
+	},
+	StorageTypes: []string{"gp3", "gp2", "io2", "io1", "st1", "sc1", "standard"},
+	// Volumes are attached as /dev/sdf to /dev/sdz; see deviceName.
+	MaxVolumes:    21,
+	UserDataLimit: 16 * 1024,
+	UserData:      renderUserData,
+}
+
+// specOf, computeSpecOf and clusterSpecOf return the typed specs set by parser.DecodeSpecs;
+// a spec that was not decoded behaves like an empty one.
+
+// specOf returns the typed spec of the provider being compiled.
+func specOf(ctx *provider.Context) *providerSpec {
+	if spec, ok := ctx.Provider.TypedSpec.(*providerSpec); ok {
+		return spec
+	}
+	return &providerSpec{}
+}
+
+// computeSpecOf returns the typed spec of an EC2 compute.
+func computeSpecOf(compute parser.Compute) *ec2Spec {
+	if spec, ok := compute.TypedSpec.(*ec2Spec); ok {
+		return spec
+	}
+	return &ec2Spec{}
+}
+
+// clusterSpecOf returns the typed spec of an EKS cluster.
+func clusterSpecOf(cluster parser.KubernetesCluster) *eksSpec {
+	if spec, ok := cluster.TypedSpec.(*eksSpec); ok {
+		return spec
+	}
+	return &eksSpec{}
+}
@@ -0,0 +1,458 @@
+// Package azure compiles manifest resources for Microsoft Azure.
+package azure
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"bold/pkg/catalog"
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+func init() {
+	provider.Register(&Backend{})
+}
+
+// Placeholder identities accepted by the mock ARM endpoint in local mode.
+const (
+	localTenantID       = "00000000-0000-0000-0000-000000000000"
+	localClientID       = "00000000-0000-0000-0000-000000000000"
+	localSubscriptionID = "00000000-0000-0000-0000-000000000000"
+)
+
+// Backend is the provider.Backend for the "azurerm" provider type.
+type Backend struct{}
+
+func (b *Backend) Info() provider.Info {
+	return provider.Info{
+		Type:        "azurerm",
+		DisplayName: "Azure",
+		ClusterType: "aks",
+		Specs:       specTypes,
+		ResourceKinds: map[string]string{
+			"azurerm_virtual_network":                      "network",
+			"azurerm_subnet":                               "subnet",
+			"azurerm_virtual_network_peering":              "peering",
+			"azurerm_network_security_group":               "security_group",
+			"azurerm_network_security_rule":                "security_group",
+			"azurerm_linux_virtual_machine":                "compute",
+			"azurerm_network_interface":                    "compute",
+			"azurerm_managed_disk":                         "compute",
+			"azurerm_virtual_machine_data_disk_attachment": "compute",
+			"azurerm_kubernetes_cluster":                   "kubernetes",
+			"azurerm_resource_group":                       "network",
+			"azurerm_kubernetes_cluster_node_pool":         "kubernetes",
+		},
+	}
+}
+
+func (b *Backend) RequiredProviders() map[string]interface{} {
+	return map[string]interface{}{
+		"azurerm": map[string]interface{}{
+			"source":  "hashicorp/azurerm",
+			"version": "~> 3.0",
+		},
+	}
+}
+
+func (b *Backend) ProviderConfig(ctx *provider.Context) map[string]interface{} {
+	config := map[string]interface{}{
+		"features": map[string]interface{}{},
+	}
+
+	if subscription := specOf(ctx).SubscriptionID; subscription != "" {
+		config["subscription_id"] = subscription
+	} else if subscription := ctx.Config.Providers.Azure.DefaultSubscription; subscription != "" {
+		config["subscription_id"] = subscription
+	}
+
+	if provider.IsLocal(ctx.Provider) {
+		// The mock ARM endpoint publishes the cloud metadata (management and storage
+		// endpoints, the latter pointing at Azurite), so the provider needs no real tenant.
+		config["metadata_host"] = ctx.Config.Providers.Azure.EmulatorHost
+		config["skip_provider_registration"] = true
+		config["use_cli"] = false
+		config["tenant_id"] = localTenantID
+		config["client_id"] = localClientID
+		config["client_secret"] = "local"
+		if _, ok := config["subscription_id"]; !ok {
+			config["subscription_id"] = localSubscriptionID
+		}
+	}
+
+	return config
+}
+
+func (b *Backend) Networks(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, network := range service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			vnetName := network.Name
+			// Every VNet gets its own resource group, shared by the resources placed in it.
+			resources.Add("azurerm_resource_group", vnetName, map[string]interface{}{
+				"name":     "rg-" + vnetName,
+				"location": region(ctx),
+				"tags":     provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": "rg-" + vnetName}),
+			})
+
+			resources.Add("azurerm_virtual_network", vnetName, map[string]interface{}{
+				"name":                vnetName,
+				"resource_group_name": resourceGroup(vnetName),
+				"location":            region(ctx),
+				"address_space":       []string{network.CIDR},
+				"tags":                provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": vnetName}),
+			})
+		}
+	}
+}
+
+func (b *Backend) Subnets(ctx *provider.Context, resources provider.Resources) {
+	for _, network := range ctx.Service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			vnetName := network.Name
+			for _, subnet := range network.Subnets {
+				subnetName := subnet.Name
+				resources.Add("azurerm_subnet", subnetName, map[string]interface{}{
+					"name":                 subnetName,
+					"resource_group_name":  resourceGroup(vnetName),
+					"virtual_network_name": vnetName,
+					"address_prefixes":     []string{subnet.CIDR},
+				})
+			}
+		}
+	}
+}
+
+func (b *Backend) Peerings(ctx *provider.Context, resources provider.Resources) {
+	for _, peering := range ctx.Service.Spec.Infrastructure.Peerings {
+		if peering.Provider == ctx.Provider.Name {
+			peeringName := peering.Name
+
+			// Azure peering is directional, so each side gets its own resource. Both networks
+			// are referenced so that OpenTofu creates them before the peerings.
+			resources.Add("azurerm_virtual_network_peering", peeringName+"-requester", map[string]interface{}{
+				"name":                         fmt.Sprintf("%s-to-%s", peering.VPCRequester, peering.VPCAccepter),
+				"resource_group_name":          resourceGroup(peering.VPCRequester),
+				"virtual_network_name":         fmt.Sprintf("${azurerm_virtual_network.%s.name}", peering.VPCRequester),
+				"remote_virtual_network_id":    fmt.Sprintf("${azurerm_virtual_network.%s.id}", peering.VPCAccepter),
+				"allow_virtual_network_access": true,
+				"allow_forwarded_traffic":      true,
+			})
+			resources.Add("azurerm_virtual_network_peering", peeringName+"-accepter", map[string]interface{}{
+				"name":                         fmt.Sprintf("%s-to-%s", peering.VPCAccepter, peering.VPCRequester),
+				"resource_group_name":          resourceGroup(peering.VPCAccepter),
+				"virtual_network_name":         fmt.Sprintf("${azurerm_virtual_network.%s.name}", peering.VPCAccepter),
+				"remote_virtual_network_id":    fmt.Sprintf("${azurerm_virtual_network.%s.id}", peering.VPCRequester),
+				"allow_virtual_network_access": true,
+				"allow_forwarded_traffic":      true,
+			})
+		}
+	}
+}
+
+func (b *Backend) SecurityGroups(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, sg := range service.Spec.Infrastructure.SecurityGroups {
+		if sg.Provider == ctx.Provider.Name {
+			sgName := sg.Name
+			resources.Add("azurerm_network_security_group", sgName, map[string]interface{}{
+				"name":                sgName,
+				"resource_group_name": resourceGroup(sg.VPC),
+				"location":            region(ctx),
+				"tags":                provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": sgName}),
+			})
+
+			for i, rule := range sg.Rules {
+				ruleName := fmt.Sprintf("%s-rule-%d", sgName, i)
+				ruleConfig := map[string]interface{}{
+					"name":                        ruleName,
+					"resource_group_name":         resourceGroup(sg.VPC),
+					"network_security_group_name": sgName,
+					"priority":                    100 + i,
+					"access":                      "Allow",
+					"source_port_range":           "*",
+					"destination_port_range":      fmt.Sprintf("%d-%d", rule.FromPort, rule.ToPort),
+				}
+
+				// The rule CIDRs are the peer of the rule: the source of inbound traffic and
+				// the destination of outbound traffic.
+				peer, local := "source", "destination"
+				if rule.Type == "ingress" {
+					ruleConfig["direction"] = "Inbound"
+				} else {
+					ruleConfig["direction"] = "Outbound"
+					peer, local = local, peer
+				}
+
+				if rule.Protocol == "tcp" {
+					ruleConfig["protocol"] = "Tcp"
+				} else if rule.Protocol == "udp" {
+					ruleConfig["protocol"] = "Udp"
+				} else {
+					ruleConfig["protocol"] = "*"
+				}
+
+				cidrs := provider.RuleCIDRBlocks(service, rule)
+				if len(cidrs) == 1 {
+					ruleConfig[peer+"_address_prefix"] = cidrs[0]
+				} else if len(cidrs) > 1 {
+					ruleConfig[peer+"_address_prefixes"] = cidrs
+				} else {
+					ruleConfig[peer+"_address_prefix"] = "*"
+				}
+
+				ruleConfig[local+"_address_prefix"] = "*"
+
+				resources.Add("azurerm_network_security_rule", ruleName, ruleConfig)
+			}
+		}
+	}
+}
+
+func (b *Backend) Computes(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, compute := range service.Spec.Infrastructure.Computes {
+		if compute.Provider == ctx.Provider.Name && compute.Type == "azurerm_linux_virtual_machine" {
+			vmName := compute.Name
+			spec := computeSpecOf(compute)
+			vm := map[string]interface{}{
+				"name":                vmName,
+				"resource_group_name": resourceGroup(compute.VPC),
+				"location":            region(ctx),
+				"size":                spec.InstanceSize(),
+				"tags":                provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": vmName}),
+			}
+			setImage(vm, compute.Image)
+
+			username := orDefault(spec.Username, "boltadmin")
+			vm["admin_username"] = username
+			if ctx.PublicKey != "" {
+				vm["admin_ssh_key"] = []map[string]interface{}{{
+					"username":   username,
+					"public_key": ctx.PublicKey,
+				}}
+			}
+			if spec.Password != nil {
+				vm["admin_password"] = ctx.SecretVar(*spec.Password)
+				vm["disable_password_authentication"] = false
+			}
+
+			osDisk := map[string]interface{}{
+				"caching":              "ReadWrite",
+				"storage_account_type": "Standard_LRS",
+			}
+			if rootDiskSize := spec.RootDiskSizeGB(); rootDiskSize > 0 {
+				osDisk["disk_size_gb"] = rootDiskSize
+			}
+			vm["os_disk"] = []map[string]interface{}{osDisk}
+
+			vm["network_interface_ids"] = []string{fmt.Sprintf("${azurerm_network_interface.%s.id}", vmName+"-nic")}
+
+			for i, storage := range compute.Storage {
+				diskName := fmt.Sprintf("%s-%s", vmName, storage.Name)
+				resources.Add("azurerm_managed_disk", diskName, map[string]interface{}{
+					"name":                 diskName,
+					"resource_group_name":  resourceGroup(compute.VPC),
+					"location":             region(ctx),
+					"storage_account_type": storage.Type,
+					"create_option":        "Empty",
+					"disk_size_gb":         storage.Size,
+					"tags":                 provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": diskName}),
+				})
+
+				resources.Add("azurerm_virtual_machine_data_disk_attachment", diskName, map[string]interface{}{
+					"managed_disk_id":    fmt.Sprintf("${azurerm_managed_disk.%s.id}", diskName),
+					"virtual_machine_id": fmt.Sprintf("${azurerm_linux_virtual_machine.%s.id}", vmName),
+					"lun":                i,
+					"caching":            "ReadWrite",
+				})
+			}
+			if userData := renderUserData(service, compute); userData != "" {
+				vm["custom_data"] = base64.StdEncoding.EncodeToString([]byte(userData))
+			}
+
+			resources.Add("azurerm_linux_virtual_machine", vmName, vm)
+
+			resources.Add("azurerm_network_interface", vmName+"-nic", map[string]interface{}{
+				"name":                vmName + "-nic",
+				"resource_group_name": resourceGroup(compute.VPC),
+				"location":            region(ctx),
+				"ip_configuration": []map[string]interface{}{{
+					"name":                          "internal",
+					"subnet_id":                     fmt.Sprintf("${azurerm_subnet.%s.id}", compute.Subnet),
+					"private_ip_address_allocation": "Dynamic",
+				}},
+			})
+
+			// The security group of a compute applies to its network interface.
+			if compute.SecurityGroup != "" {
+				resources.Add("azurerm_network_interface_security_group_association", vmName+"-nic", map[string]interface{}{
+					"network_interface_id":      fmt.Sprintf("${azurerm_network_interface.%s.id}", vmName+"-nic"),
+					"network_security_group_id": fmt.Sprintf("${azurerm_network_security_group.%s.id}", compute.SecurityGroup),
+				})
+			}
+		}
+	}
+}
+
+func (b *Backend) Cluster(ctx *provider.Context, cluster parser.KubernetesCluster, resources provider.Resources) {
+	service := ctx.Service
+	clusterName := cluster.Name
+	spec := clusterSpecOf(cluster)
+	pools := spec.NodePools()
+	subnets := provider.ClusterSubnets(service, cluster)
+
+	aks := map[string]interface{}{
+		"name":                clusterName,
+		"location":            region(ctx),
+		"resource_group_name": resourceGroup(cluster.VPC),
+		"dns_prefix":          clusterName,
+		"default_node_pool":   nodePool(pools[0], "default", subnets),
+		"identity": map[string]interface{}{
+			"type": "SystemAssigned",
+		},
+		"network_profile": map[string]interface{}{
+			"network_plugin": "azure",
+			"network_policy": "azure",
+			// The service range must not overlap the VNet the nodes are placed in.
+			"service_cidr":   orDefault(spec.ServiceCIDR, "172.20.0.0/16"),
+			"dns_service_ip": orDefault(spec.DNSServiceIP, "172.20.0.10"),
+		},
+		"tags": provider.MergeTags(service.Metadata.Tags, map[string]string{"Name": clusterName}),
+	}
+	if spec.Version != "" {
+		aks["kubernetes_version"] = spec.Version
+	}
+	resources.Add("azurerm_kubernetes_cluster", clusterName, aks)
+
+	for _, pool := range pools[1:] {
+		config := nodePool(pool, pool.Name, subnets)
+		config["kubernetes_cluster_id"] = fmt.Sprintf("${azurerm_kubernetes_cluster.%s.id}", clusterName)
+		config["vm_size"] = pool.Size
+		if spec.Version != "" {
+			config["orchestrator_version"] = spec.Version
+		}
+		resources.Add("azurerm_kubernetes_cluster_node_pool", fmt.Sprintf("%s-%s", clusterName, pool.Name), config)
+	}
+}
+
+// orDefault returns value, or defaultValue when value is empty.
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// setImage sets the image of a virtual machine. An image id is either a
+// publisher:offer:sku:version URN or the resource ID of a custom image.
+func setImage(vm map[string]interface{}, image *parser.Image) {
+	if image != nil && image.ID != "" {
+		if urn := strings.Split(image.ID, ":"); len(urn) == 4 {
+			vm["source_image_reference"] = map[string]interface{}{
+				"publisher": urn[0],
+				"offer":     urn[1],
+				"sku":       urn[2],
+				"version":   urn[3],
+			}
+		} else {
+			vm["source_image_id"] = image.ID
+		}
+		return
+	}
+
+	// Images the catalog does not know are rejected by parser.DecodeSpecs.
+	reference, _ := catalog.LookupAzureImage(image.OSImage())
+	vm["source_image_reference"] = map[string]interface{}{
+		"publisher": reference.Publisher,
+		"offer":     reference.Offer,
+		"sku":       reference.SKU,
+		"version":   "latest",
+	}
+}
+
+// volumeMounts returns the data disks of a virtual machine that are mounted at a path.
+func volumeMounts(compute parser.Compute) []provider.VolumeMount {
+	var mounts []provider.VolumeMount
+	for i, storage := range compute.Storage {
+		if storage.Path != "" {
+			mounts = append(mounts, provider.VolumeMount{
+				Path:    storage.Path,
+				Devices: []string{fmt.Sprintf("/dev/disk/azure/scsi1/lun%d", i)},
+			})
+		}
+	}
+	return mounts
+}
+
+// renderUserData renders the custom data of a virtual machine, which also mounts its data
+// disks, before it is base64 encoded.
+func renderUserData(service *parser.Service, compute parser.Compute) string {
+	return provider.UserData(service, compute, volumeMounts(compute))
+}
+
+// nodePool returns the settings shared by the AKS default node pool and additional node pools.
+// Pools run in their own subnet if set, otherwise in the first subnet of the cluster.
+func nodePool(pool parser.NodePool, defaultName string, subnets []string) map[string]interface{} {
+	name := pool.Name
+	if name == "" {
+		name = defaultName
+	}
+
+	config := map[string]interface{}{
+		"name":    aksPoolName(name),
+		"vm_size": pool.Size,
+	}
+
+	subnet := pool.Subnet
+	if subnet == "" && len(subnets) > 0 {
+		subnet = subnets[0]
+	}
+	if subnet != "" {
+		config["vnet_subnet_id"] = fmt.Sprintf("${azurerm_subnet.%s.id}", subnet)
+	}
+
+	if pool.Autoscaling() {
+		config["enable_auto_scaling"] = true
+		config["min_count"] = pool.Min
+		config["max_count"] = pool.Max
+	} else {
+		config["node_count"] = pool.Count
+	}
+	return config
+}
+
+// aksPoolName converts a pool name to what AKS accepts: lowercase letters and digits, at most 12 characters.
+func aksPoolName(name string) string {
+	var result []rune
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			result = append(result, r)
+		}
+	}
+	if len(result) > 12 {
+		result = result[:12]
+	}
+	if len(result) == 0 {
+		return "default"
+	}
+	return string(result)
+}
+
+// resourceGroup returns a reference to the resource group created for a VNet.
+func resourceGroup(vnet string) string {
+	return fmt.Sprintf("${azurerm_resource_group.%s.name}", vnet)
+}
+
+// region returns the provider spec region, falling back to providers.azure.default_region.
+func region(ctx *provider.Context) string {
+	if region := specOf(ctx).Region; region != "" {
+		return region
+	}
+	return ctx.Config.Providers.Azure.DefaultRegion
+}
@@ -0,0 +1,124 @@
+package azure
+
+import (
+	"errors"
+
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+// providerSpec is the typed spec of an "azurerm" provider.
+type providerSpec struct {
+	parser.ProviderSettings `yaml:",inline"`
+	SubscriptionID          string `yaml:"subscription_id,omitempty"`
+}
+
+// vmSpec is the typed spec of an "azurerm_linux_virtual_machine" compute.
+type vmSpec struct {
+	// The size key of ComputeSizing also accepts a VM size.
+	parser.ComputeSizing `yaml:",inline"`
+	RootDiskSize         int               `yaml:"root_disk_size_gb,omitempty"`
+	Username             string            `yaml:"username,omitempty"`
+	Password             *parser.SecretRef `yaml:"password,omitempty"`
+}
+
+func (s *vmSpec) InstanceSize() string {
+	return provider.InstanceSize("azurerm", "", s.ComputeSizing, "Standard_B1s")
+}
+
+func (s *vmSpec) RootDiskSizeGB() int {
+	return s.RootDiskSize
+}
+
+// Check rejects a VM without credentials: it is only given an SSH key when the service has
+// a key pair, and azurerm cannot create a VM with neither a key nor a password.
+func (s *vmSpec) Check(service *parser.Service) error {
+	if service.Spec.KeyPair.Name == "" && s.Password == nil {
+		return errors.New("azurerm VM needs a key_pair or spec.password")
+	}
+	return nil
+}
+
+// aksSpec is the typed spec of an AKS cluster.
+type aksSpec struct {
+	Version      string         `yaml:"version,omitempty"`
+	NodeSize     string         `yaml:"node_size,omitempty"`
+	NodeCount    int            `yaml:"node_count,omitempty"`
+	MinNodes     int            `yaml:"min_nodes,omitempty"`
+	MaxNodes     int            `yaml:"max_nodes,omitempty"`
+	Subnets      []string       `yaml:"subnets,omitempty"`
+	Pools        []nodePoolSpec `yaml:"node_pools,omitempty"`
+	ServiceCIDR  string         `yaml:"service_cidr,omitempty"`
+	DNSServiceIP string         `yaml:"dns_service_ip,omitempty"`
+}
+
+// nodePoolSpec is an entry of node_pools in an AKS cluster spec.
+type nodePoolSpec struct {
+	Name      string `yaml:"name,omitempty"`
+	NodeSize  string `yaml:"node_size,omitempty"`
+	NodeCount int    `yaml:"node_count,omitempty"`
+	MinNodes  int    `yaml:"min_nodes,omitempty"`
+	MaxNodes  int    `yaml:"max_nodes,omitempty"`
+	Subnet    string `yaml:"subnet,omitempty"`
+}
+
+func (s *aksSpec) NodePools() []parser.NodePool {
+	var listed []parser.NodePool
+	for _, pool := range s.Pools {
+		listed = append(listed, parser.NodePool{
+			Name:   pool.Name,
+			Size:   pool.NodeSize,
+			Count:  pool.NodeCount,
+			Min:    pool.MinNodes,
+			Max:    pool.MaxNodes,
+			Subnet: pool.Subnet,
+		})
+	}
+	defaults := parser.NodePool{Size: s.NodeSize, Count: s.NodeCount, Min: s.MinNodes, Max: s.MaxNodes}
+	return provider.NodePools(defaults, "Standard_B2s", listed)
+}
+
+func (s *aksSpec) SubnetNames() []string {
+	return s.Subnets
+}
+
+var specTypes = parser.SpecTypes{
+	Provider: func() parser.ProviderSpec { return &providerSpec{} },
+	Cluster:  func() parser.ClusterSpec { return &aksSpec{} },
+	Computes: map[string]func() parser.ComputeSpec{
+		"azurerm_linux_virtual_machine": func() parser.ComputeSpec { return &vmSpec{} },
+	},
+	StorageTypes: []string{"Standard_LRS", "StandardSSD_LRS", "StandardSSD_ZRS", "Premium_LRS", "Premium_ZRS", "PremiumV2_LRS", "UltraSSD_LRS"},
+	// Data disks use LUNs 0 to 63.
+	MaxVolumes: 64,
+	// custom_data is limited to 64 KB once base64 encoded.
+	UserDataLimit: 64 * 1024 * 3 / 4,
+	UserData:      renderUserData,
+}
+
+// specOf, computeSpecOf and clusterSpecOf return the typed specs set by parser.DecodeSpecs;
+// a spec that was not decoded behaves like an empty one.
+
+// specOf returns the typed spec of the provider being compiled.
+func specOf(ctx *provider.Context) *providerSpec {
+	if spec, ok := ctx.Provider.TypedSpec.(*providerSpec); ok {
+		return spec
+	}
+	return &providerSpec{}
+}
+
+// computeSpecOf returns the typed spec of a virtual machine.
+func computeSpecOf(compute parser.Compute) *vmSpec {
+	if spec, ok := compute.TypedSpec.(*vmSpec); ok {
+		return spec
+	}
+	return &vmSpec{}
+}
+
+// clusterSpecOf returns the typed spec of an AKS cluster.
+func clusterSpecOf(cluster parser.KubernetesCluster) *aksSpec {
+	if spec, ok := cluster.TypedSpec.(*aksSpec); ok {
+		return spec
+	}
+	return &aksSpec{}
+}
@@ -0,0 +1,9 @@
+// Package builtin registers the provider backends that ship with Bolt.
+// Import it for its side effects wherever manifests are parsed or compiled.
+package builtin
+
+import (
+	_ "bold/pkg/provider/aws"
+	_ "bold/pkg/provider/azure"
+	_ "bold/pkg/provider/google"
+)
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"fmt"
+
+	"bold/pkg/parser"
+)
+
+// defaultNodeCount is the node count of pools that do not set one.
+const defaultNodeCount = 2
+
+// NodePools applies defaults to the node pools of a cluster spec. defaults holds the
+// top-level spec keys, with defaultSize used when they do not set a size. Unset values of
+// listed pools fall back to defaults and unnamed pools are named pool<n>; without listed
+// pools, defaults form a single unnamed pool.
+func NodePools(defaults parser.NodePool, defaultSize string, listed []parser.NodePool) []parser.NodePool {
+	if defaults.Size == "" {
+		defaults.Size = defaultSize
+	}
+	if defaults.Count == 0 {
+		defaults.Count = defaultNodeCount
+	}
+
+	if len(listed) == 0 {
+		return []parser.NodePool{withMinimum(defaults)}
+	}
+
+	pools := make([]parser.NodePool, 0, len(listed))
+	for i, pool := range listed {
+		if pool.Name == "" {
+			pool.Name = fmt.Sprintf("pool%d", i+1)
+		}
+		if pool.Size == "" {
+			pool.Size = defaults.Size
+		}
+		if pool.Count == 0 {
+			pool.Count = defaults.Count
+		}
+		if pool.Min == 0 {
+			pool.Min = defaults.Min
+		}
+		if pool.Max == 0 {
+			pool.Max = defaults.Max
+		}
+		if pool.DiskSizeGB == 0 {
+			pool.DiskSizeGB = defaults.DiskSizeGB
+		}
+		pools = append(pools, withMinimum(pool))
+	}
+	return pools
+}
+
+// withMinimum defaults the minimum of an autoscaling pool to one node.
+func withMinimum(pool parser.NodePool) parser.NodePool {
+	if pool.Autoscaling() && pool.Min == 0 {
+		pool.Min = 1
+	}
+	return pool
+}
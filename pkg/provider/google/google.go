@@ -0,0 +1,381 @@
+// Package google compiles manifest resources for Google Cloud Platform.
+package google
+
+import (
+	"fmt"
+	"strings"
+
+	"bold/pkg/catalog"
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+func init() {
+	provider.Register(&Backend{})
+}
+
+// localProject is the project used in local mode when none is configured.
+const localProject = "bolt-local"
+
+// sshUser is the user the service key pair is installed for, matching the Azure admin user.
+const sshUser = "boltadmin"
+
+// Backend is the provider.Backend for the "google" provider type.
+type Backend struct{}
+
+func (b *Backend) Info() provider.Info {
+	return provider.Info{
+		Type:        "google",
+		DisplayName: "GCP",
+		ClusterType: "gke",
+		Specs:       specTypes,
+		ResourceKinds: map[string]string{
+			"google_compute_network":         "network",
+			"google_compute_subnetwork":      "subnet",
+			"google_compute_network_peering": "peering",
+			"google_compute_firewall":        "security_group",
+			"google_compute_instance":        "compute",
+			"google_compute_disk":            "compute",
+			"google_container_cluster":       "kubernetes",
+			"google_container_node_pool":     "kubernetes",
+		},
+	}
+}
+
+func (b *Backend) RequiredProviders() map[string]interface{} {
+	return map[string]interface{}{
+		"google": map[string]interface{}{
+			"source":  "hashicorp/google",
+			"version": "~> 5.0",
+		},
+	}
+}
+
+func (b *Backend) ProviderConfig(ctx *provider.Context) map[string]interface{} {
+	config := map[string]interface{}{}
+
+	// Without a project in the manifest or the config, the provider falls back to
+	// GOOGLE_PROJECT / application default credentials.
+	if project := projectID(ctx); project != "" {
+		config["project"] = project
+	}
+
+	config["region"] = region(ctx)
+	config["zone"] = zone(ctx)
+
+	if provider.IsLocal(ctx.Provider) {
+		// A static token skips the application default credentials lookup.
+		config["access_token"] = "local"
+		if _, ok := config["project"]; !ok {
+			config["project"] = localProject
+		}
+
+		emulator := strings.TrimSuffix(ctx.Config.Providers.GCP.EmulatorURL, "/")
+		config["compute_custom_endpoint"] = emulator + "/compute/v1/"
+		config["container_custom_endpoint"] = emulator + "/v1/"
+		config["iam_custom_endpoint"] = emulator + "/v1/"
+		config["resource_manager_custom_endpoint"] = emulator + "/v1/"
+		config["storage_custom_endpoint"] = strings.TrimSuffix(ctx.Config.Providers.GCP.StorageEmulatorURL, "/") + "/storage/v1/"
+	}
+
+	return config
+}
+
+func (b *Backend) Networks(ctx *provider.Context, resources provider.Resources) {
+	for _, network := range ctx.Service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			resources.Add("google_compute_network", network.Name, map[string]interface{}{
+				"name":                    network.Name,
+				"auto_create_subnetworks": false,
+			})
+		}
+	}
+}
+
+func (b *Backend) Subnets(ctx *provider.Context, resources provider.Resources) {
+	for _, network := range ctx.Service.Spec.Infrastructure.Networks {
+		if network.Provider == ctx.Provider.Name {
+			for _, subnet := range network.Subnets {
+				subnetName := subnet.Name
+				resources.Add("google_compute_subnetwork", subnetName, map[string]interface{}{
+					"name":          subnetName,
+					"ip_cidr_range": subnet.CIDR,
+					"network":       fmt.Sprintf("${google_compute_network.%s.self_link}", network.Name),
+					"region":        region(ctx),
+				})
+			}
+		}
+	}
+}
+
+func (b *Backend) Peerings(ctx *provider.Context, resources provider.Resources) {
+	for _, peering := range ctx.Service.Spec.Infrastructure.Peerings {
+		if peering.Provider == ctx.Provider.Name {
+			peeringName := peering.Name
+
+			// GCP peering only becomes ACTIVE once both networks have a peering to each other.
+			resources.Add("google_compute_network_peering", peeringName+"-requester", map[string]interface{}{
+				"name":         fmt.Sprintf("%s-to-%s", peering.VPCRequester, peering.VPCAccepter),
+				"network":      fmt.Sprintf("${google_compute_network.%s.self_link}", peering.VPCRequester),
+				"peer_network": fmt.Sprintf("${google_compute_network.%s.self_link}", peering.VPCAccepter),
+			})
+			resources.Add("google_compute_network_peering", peeringName+"-accepter", map[string]interface{}{
+				"name":         fmt.Sprintf("%s-to-%s", peering.VPCAccepter, peering.VPCRequester),
+				"network":      fmt.Sprintf("${google_compute_network.%s.self_link}", peering.VPCAccepter),
+				"peer_network": fmt.Sprintf("${google_compute_network.%s.self_link}", peering.VPCRequester),
+				"depends_on":   []string{fmt.Sprintf("google_compute_network_peering.%s-requester", peeringName)},
+			})
+		}
+	}
+}
+
+func (b *Backend) SecurityGroups(ctx *provider.Context, resources provider.Resources) {
+	service := ctx.Service
+
+	for _, sg := range service.Spec.Infrastructure.SecurityGroups {
+		if sg.Provider == ctx.Provider.Name {
+			sgName := sg.Name
+
+			for i, rule := range sg.Rules {
+				ruleName := fmt.Sprintf("%s-rule-%d", sgName, i)
+				ruleConfig := map[string]interface{}{
+					"name":    ruleName,
+					"network": fmt.Sprintf("${google_compute_network.%s.self_link}", sg.VPC),
+				}
+
+				if rule.Type == "ingress" {
+					ruleConfig["direction"] = "INGRESS"
+					ruleConfig["source_ranges"] = provider.RuleCIDRBlocks(service, rule)
+					ruleConfig["target_tags"] = []string{sgName}
+				} else {
+					ruleConfig["direction"] = "EGRESS"
+					ruleConfig["destination_ranges"] = provider.RuleCIDRBlocks(service, rule)
+					ruleConfig["target_tags"] = []string{sgName}
+				}
+
+				if rule.Protocol == "tcp" || rule.Protocol == "udp" {
+					ruleConfig["allow"] = []map[string]interface{}{{
+						"protocol": rule.Protocol,
+						"ports":    []string{fmt.Sprintf("%d-%d", rule.FromPort, rule.ToPort)},
+					}}
+				} else {
+					ruleConfig["allow"] = []map[string]interface{}{{
+						"protocol": rule.Protocol,
+					}}
+				}
+
+				resources.Add("google_compute_firewall", ruleName, ruleConfig)
+			}
+		}
+	}
+}
+
+func (b *Backend) Computes(ctx *provider.Context, resources provider.Resources) {
+	for _, compute := range ctx.Service.Spec.Infrastructure.Computes {
+		if compute.Provider == ctx.Provider.Name && compute.Type == "google_compute_instance" {
+			vmName := compute.Name
+			spec := computeSpecOf(compute)
+
+			vmZone := zone(ctx)
+			if spec.Zone != "" {
+				vmZone = spec.Zone
+			}
+			rootDiskSize := spec.RootDiskSizeGB()
+			if rootDiskSize == 0 {
+				rootDiskSize = 20
+			}
+
+			vm := map[string]interface{}{
+				"name":         vmName,
+				"machine_type": spec.InstanceSize(),
+				"zone":         vmZone,
+				"boot_disk": []map[string]interface{}{{
+					"initialize_params": []map[string]interface{}{{
+						"image": bootImage(compute.Image),
+						"size":  rootDiskSize,
+					}},
+				}},
+			}
+
+			networkInterface := map[string]interface{}{
+				"subnetwork": fmt.Sprintf("${google_compute_subnetwork.%s.self_link}", compute.Subnet),
+			}
+
+			if compute.SecurityGroup != "" {
+				networkInterface["access_config"] = []map[string]interface{}{{
+					"network_tier": "STANDARD",
+				}}
+				vm["tags"] = []string{compute.SecurityGroup}
+			}
+
+			vm["network_interface"] = []map[string]interface{}{networkInterface}
+
+			var attachedDisks []map[string]interface{}
+			for _, storage := range compute.Storage {
+				diskName := fmt.Sprintf("%s-%s", vmName, storage.Name)
+				resources.Add("google_compute_disk", diskName, map[string]interface{}{
+					"name": diskName,
+					"type": storage.Type,
+					"zone": vm["zone"],
+					"size": storage.Size,
+				})
+
+				attachedDisks = append(attachedDisks, map[string]interface{}{
+					"source":      fmt.Sprintf("${google_compute_disk.%s.id}", diskName),
+					"device_name": storage.Name,
+				})
+			}
+			if len(attachedDisks) > 0 {
+				vm["attached_disk"] = attachedDisks
+			}
+			metadata := map[string]string{}
+			if userData := renderUserData(ctx.Service, compute); userData != "" {
+				metadata["user-data"] = userData
+			}
+			if ctx.PublicKey != "" {
+				metadata["ssh-keys"] = sshUser + ":" + ctx.PublicKey
+			}
+			if len(metadata) > 0 {
+				vm["metadata"] = metadata
+			}
+
+			resources.Add("google_compute_instance", vmName, vm)
+		}
+	}
+}
+
+func (b *Backend) Cluster(ctx *provider.Context, cluster parser.KubernetesCluster, resources provider.Resources) {
+	clusterName := cluster.Name
+	spec := clusterSpecOf(cluster)
+	pools := spec.NodePools()
+
+	location := spec.Location
+	if location == "" {
+		location = region(ctx)
+	}
+
+	gke := map[string]interface{}{
+		"name":                     clusterName,
+		"location":                 location,
+		"remove_default_node_pool": true,
+		"initial_node_count":       1,
+		"network":                  fmt.Sprintf("${google_compute_network.%s.name}", cluster.VPC),
+		"ip_allocation_policy": map[string]interface{}{
+			"cluster_ipv4_cidr_block":  "/16",
+			"services_ipv4_cidr_block": "/22",
+		},
+		"private_cluster_config": map[string]interface{}{
+			"enable_private_nodes":    true,
+			"enable_private_endpoint": false,
+			"master_ipv4_cidr_block":  "172.16.0.0/28",
+		},
+	}
+	// GKE places every node pool in the cluster subnetwork.
+	if subnets := provider.ClusterSubnets(ctx.Service, cluster); len(subnets) > 0 {
+		gke["subnetwork"] = fmt.Sprintf("${google_compute_subnetwork.%s.name}", subnets[0])
+	}
+	if spec.Version != "" {
+		gke["min_master_version"] = spec.Version
+	}
+	resources.Add("google_container_cluster", clusterName, gke)
+
+	for _, pool := range pools {
+		key, name := clusterName, fmt.Sprintf("%s-node-pool", clusterName)
+		if pool.Name != "" {
+			key, name = fmt.Sprintf("%s-%s", clusterName, pool.Name), pool.Name
+		}
+
+		diskSize := pool.DiskSizeGB
+		if diskSize == 0 {
+			diskSize = 20
+		}
+
+		nodePool := map[string]interface{}{
+			"name":     name,
+			"location": fmt.Sprintf("${google_container_cluster.%s.location}", clusterName),
+			"cluster":  fmt.Sprintf("${google_container_cluster.%s.name}", clusterName),
+			"node_config": map[string]interface{}{
+				"machine_type": pool.Size,
+				"disk_size_gb": diskSize,
+				"oauth_scopes": []string{
+					"https://www.googleapis.com/auth/logging.write",
+					"https://www.googleapis.com/auth/monitoring",
+				},
+				"metadata": map[string]string{
+					"disable-legacy-endpoints": "true",
+				},
+			},
+		}
+		if pool.Autoscaling() {
+			nodePool["initial_node_count"] = pool.Min
+			nodePool["autoscaling"] = map[string]interface{}{
+				"min_node_count": pool.Min,
+				"max_node_count": pool.Max,
+			}
+		} else {
+			nodePool["node_count"] = pool.Count
+		}
+		if spec.Version != "" {
+			nodePool["version"] = spec.Version
+		}
+		resources.Add("google_container_node_pool", key, nodePool)
+	}
+}
+
+// projectID returns the provider spec project, then providers.gcp.default_project, then defaults.project.
+func projectID(ctx *provider.Context) string {
+	if project := specOf(ctx).Project; project != "" {
+		return project
+	}
+	if project := ctx.Config.Providers.GCP.DefaultProject; project != "" {
+		return project
+	}
+	return ctx.Config.Defaults.Project
+}
+
+// bootImage returns the boot disk image of an instance: the image id, or the latest image of
+// the catalog image family.
+func bootImage(image *parser.Image) string {
+	if image != nil && image.ID != "" {
+		return image.ID
+	}
+
+	// Images the catalog does not know are rejected by parser.DecodeSpecs.
+	family, _ := catalog.LookupGoogleImage(image.OSImage())
+	return family.Project + "/" + family.Family
+}
+
+// volumeMounts returns the persistent disks of an instance that are mounted at a path.
+func volumeMounts(compute parser.Compute) []provider.VolumeMount {
+	var mounts []provider.VolumeMount
+	for _, storage := range compute.Storage {
+		if storage.Path != "" {
+			mounts = append(mounts, provider.VolumeMount{
+				Path:    storage.Path,
+				Devices: []string{"/dev/disk/by-id/google-" + storage.Name},
+			})
+		}
+	}
+	return mounts
+}
+
+// renderUserData renders the user-data metadata of an instance, which also mounts its disks.
+func renderUserData(service *parser.Service, compute parser.Compute) string {
+	return provider.UserData(service, compute, volumeMounts(compute))
+}
+
+// region returns the provider spec region, falling back to providers.gcp.default_region.
+func region(ctx *provider.Context) string {
+	if region := specOf(ctx).Region; region != "" {
+		return region
+	}
+	return ctx.Config.Providers.GCP.DefaultRegion
+}
+
+// zone returns the provider spec zone, falling back to the first zone of the region.
+func zone(ctx *provider.Context) string {
+	if zone := specOf(ctx).Zone; zone != "" {
+		return zone
+	}
+	return region(ctx) + "-a"
+}
@@ -0,0 +1,114 @@
+package google
+
+import (
+	"bold/pkg/parser"
+	"bold/pkg/provider"
+)
+
+// providerSpec is the typed spec of a "google" provider.
+type providerSpec struct {
+	parser.ProviderSettings `yaml:",inline"`
+	Project                 string `yaml:"project,omitempty"`
+	Zone                    string `yaml:"zone,omitempty"`
+}
+
+// instanceSpec is the typed spec of a "google_compute_instance" compute.
+type instanceSpec struct {
+	parser.ComputeSizing `yaml:",inline"`
+	MachineType          string `yaml:"machine_type,omitempty"`
+	Zone                 string `yaml:"zone,omitempty"`
+	RootDiskSize         int    `yaml:"root_disk_size_gb,omitempty"`
+}
+
+func (s *instanceSpec) InstanceSize() string {
+	return provider.InstanceSize("google", s.MachineType, s.ComputeSizing, "e2-medium")
+}
+
+func (s *instanceSpec) RootDiskSizeGB() int {
+	return s.RootDiskSize
+}
+
+// gkeSpec is the typed spec of a GKE cluster.
+type gkeSpec struct {
+	Version        string         `yaml:"version,omitempty"`
+	MachineType    string         `yaml:"machine_type,omitempty"`
+	NodeCount      int            `yaml:"node_count,omitempty"`
+	MinNodes       int            `yaml:"min_nodes,omitempty"`
+	MaxNodes       int            `yaml:"max_nodes,omitempty"`
+	Subnets        []string       `yaml:"subnets,omitempty"`
+	Pools          []nodePoolSpec `yaml:"node_pools,omitempty"`
+	Location       string         `yaml:"location,omitempty"`
+	NodeDiskSizeGB int            `yaml:"node_disk_size_gb,omitempty"`
+}
+
+// nodePoolSpec is an entry of node_pools in a GKE cluster spec. GKE places every pool in
+// the cluster subnetwork, so pools have no subnet of their own.
+type nodePoolSpec struct {
+	Name           string `yaml:"name,omitempty"`
+	MachineType    string `yaml:"machine_type,omitempty"`
+	NodeCount      int    `yaml:"node_count,omitempty"`
+	MinNodes       int    `yaml:"min_nodes,omitempty"`
+	MaxNodes       int    `yaml:"max_nodes,omitempty"`
+	NodeDiskSizeGB int    `yaml:"node_disk_size_gb,omitempty"`
+}
+
+func (s *gkeSpec) NodePools() []parser.NodePool {
+	var listed []parser.NodePool
+	for _, pool := range s.Pools {
+		listed = append(listed, parser.NodePool{
+			Name:       pool.Name,
+			Size:       pool.MachineType,
+			Count:      pool.NodeCount,
+			Min:        pool.MinNodes,
+			Max:        pool.MaxNodes,
+			DiskSizeGB: pool.NodeDiskSizeGB,
+		})
+	}
+	defaults := parser.NodePool{Size: s.MachineType, Count: s.NodeCount, Min: s.MinNodes, Max: s.MaxNodes, DiskSizeGB: s.NodeDiskSizeGB}
+	return provider.NodePools(defaults, "e2-medium", listed)
+}
+
+func (s *gkeSpec) SubnetNames() []string {
+	return s.Subnets
+}
+
+var specTypes = parser.SpecTypes{
+	Provider: func() parser.ProviderSpec { return &providerSpec{} },
+	Cluster:  func() parser.ClusterSpec { return &gkeSpec{} },
+	Computes: map[string]func() parser.ComputeSpec{
+		"google_compute_instance": func() parser.ComputeSpec { return &instanceSpec{} },
+	},
+	StorageTypes: []string{"pd-standard", "pd-balanced", "pd-ssd", "pd-extreme", "hyperdisk-balanced", "hyperdisk-extreme", "hyperdisk-throughput"},
+	// An instance attaches at most 128 disks, including its boot disk.
+	MaxVolumes: 127,
+	// A single metadata value holds at most 256 KB.
+	UserDataLimit: 256 * 1024,
+	UserData:      renderUserData,
+}
+
+// specOf, computeSpecOf and clusterSpecOf return the typed specs set by parser.DecodeSpecs;
+// a spec that was not decoded behaves like an empty one.
+
+// specOf returns the typed spec of the provider being compiled.
+func specOf(ctx *provider.Context) *providerSpec {
+	if spec, ok := ctx.Provider.TypedSpec.(*providerSpec); ok {
+		return spec
+	}
+	return &providerSpec{}
+}
+
+// computeSpecOf returns the typed spec of a compute instance.
+func computeSpecOf(compute parser.Compute) *instanceSpec {
+	if spec, ok := compute.TypedSpec.(*instanceSpec); ok {
+		return spec
+	}
+	return &instanceSpec{}
+}
+
+// clusterSpecOf returns the typed spec of a GKE cluster.
+func clusterSpecOf(cluster parser.KubernetesCluster) *gkeSpec {
+	if spec, ok := cluster.TypedSpec.(*gkeSpec); ok {
+		return spec
+	}
+	return &gkeSpec{}
+}
@@ -0,0 +1,151 @@
+// Package provider defines the interface every cloud backend implements and the
+// registry the compiler, validation, cost and graph packages consult to find them.
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"bold/pkg/config"
+	"bold/pkg/parser"
+)
+
+// Backend translates manifest resources for a single cloud into OpenTofu JSON resources.
+// Backends live in their own packages and register themselves from init with Register.
+type Backend interface {
+	// Info describes the backend to the rest of Bolt.
+	Info() Info
+
+	// RequiredProviders returns the entries this backend needs in terraform.required_providers.
+	RequiredProviders() map[string]interface{}
+
+	// ProviderConfig returns the provider block for a manifest provider of this type.
+	ProviderConfig(ctx *Context) map[string]interface{}
+
+	// Networks, Subnets, Peerings, SecurityGroups and Computes add the resources for the
+	// manifest objects that belong to ctx.Provider.
+	Networks(ctx *Context, resources Resources)
+	Subnets(ctx *Context, resources Resources)
+	Peerings(ctx *Context, resources Resources)
+	SecurityGroups(ctx *Context, resources Resources)
+	Computes(ctx *Context, resources Resources)
+
+	// Cluster adds the resources for a single Kubernetes cluster.
+	Cluster(ctx *Context, cluster parser.KubernetesCluster, resources Resources)
+}
+
+// Info is the static description of a backend.
+type Info struct {
+	// Type is the manifest provider type handled by the backend, e.g. "aws".
+	Type string
+	// DisplayName is the human readable cloud name, e.g. "AWS".
+	DisplayName string
+	// ClusterType is the managed Kubernetes offering, e.g. "eks".
+	ClusterType string
+	// Specs lists the typed specs that manifest provider, cluster and compute specs are
+	// decoded into; its Computes keys are the compute `type` values the backend compiles.
+	Specs parser.SpecTypes
+	// ResourceKinds maps generated OpenTofu resource types to manifest kinds
+	// (network, subnet, peering, security_group, compute, kubernetes).
+	ResourceKinds map[string]string
+}
+
+// Context carries the manifest, the manifest provider a backend is compiling for, and the
+// loaded Bolt configuration that supplies defaults such as regions and emulator endpoints.
+type Context struct {
+	Config   *config.Config
+	Service  *parser.Service
+	Provider parser.Provider
+	// PublicKey is the public key of the service key pair in authorized_keys format, or ""
+	// when the service has no key pair.
+	PublicKey string
+	// Secrets maps the sensitive OpenTofu variables that carry secrets to the secret
+	// references they are resolved from; see SecretVar.
+	Secrets map[string]string
+	// DataSources collects the data sources the generated resources read, such as image
+	// lookups. It is shared by all backends and compiled into the top-level data block.
+	DataSources Resources
+}
+
+var (
+	mu       sync.RWMutex
+	backends = make(map[string]Backend)
+)
+
+// Register makes a backend available under its Info().Type. Registering the same
+// type twice panics, as it indicates two packages claiming the same cloud.
+func Register(backend Backend) {
+	providerType := backend.Info().Type
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if providerType == "" {
+		panic("provider: Register called with empty provider type")
+	}
+	if _, exists := backends[providerType]; exists {
+		panic(fmt.Sprintf("provider: Register called twice for provider type %q", providerType))
+	}
+	backends[providerType] = backend
+	parser.RegisterProviderType(providerType, backend.Info().Specs)
+}
+
+// Lookup returns the backend registered for a provider type.
+func Lookup(providerType string) (Backend, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	backend, ok := backends[providerType]
+	return backend, ok
+}
+
+// Types returns the registered provider types in sorted order.
+func Types() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	types := make([]string, 0, len(backends))
+	for providerType := range backends {
+		types = append(types, providerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Resolve finds the manifest provider and backend for a provider reference, as used in the
+// `provider` field of networks, computes and clusters. References are manifest provider names.
+func Resolve(service *parser.Service, name string) (parser.Provider, Backend, bool) {
+	for _, p := range service.Providers {
+		if p.Name == name {
+			backend, ok := Lookup(p.Type)
+			return p, backend, ok
+		}
+	}
+	return parser.Provider{}, nil, false
+}
+
+// IsLocal reports whether a manifest provider targets a local emulator rather than a real cloud.
+func IsLocal(p parser.Provider) bool {
+	if p.TypedSpec != nil && p.TypedSpec.Settings().Environment != "" {
+		return p.TypedSpec.Settings().Environment == "local"
+	}
+	return strings.Contains(strings.ToLower(p.Name), "local")
+}
+
+var unsafeVariableChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// SecretVar returns a reference to the sensitive variable that carries a secret and records
+// the secret in ctx.Secrets, so that the compiler declares the variable and passes its value
+// to OpenTofu without writing it to disk.
+func (c *Context) SecretVar(ref parser.SecretRef) string {
+	base := "secret_" + strings.Trim(unsafeVariableChars.ReplaceAllString(strings.ToLower(ref.Secret), "_"), "_")
+	name := base
+	for i := 2; c.Secrets[name] != "" && c.Secrets[name] != ref.Secret; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	c.Secrets[name] = ref.Secret
+	return fmt.Sprintf("${var.%s}", name)
+}
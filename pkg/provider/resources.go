@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"bold/pkg/parser"
+)
+
+// Resources collects generated OpenTofu resources keyed by resource type and then resource name.
+type Resources map[string]map[string]interface{}
+
+// Add stores the configuration of a resource, replacing any previous resource with the same address.
+func (r Resources) Add(resourceType, name string, config interface{}) {
+	if r[resourceType] == nil {
+		r[resourceType] = make(map[string]interface{})
+	}
+	r[resourceType][name] = config
+}
+
+// FindNetwork returns the manifest network with the given name, or nil if it is not declared.
+func FindNetwork(service *parser.Service, name string) *parser.Network {
+	for i := range service.Spec.Infrastructure.Networks {
+		if service.Spec.Infrastructure.Networks[i].Name == name {
+			return &service.Spec.Infrastructure.Networks[i]
+		}
+	}
+	return nil
+}
+
+// RuleCIDRBlocks returns the address ranges a security group rule applies to: its
+// explicit cidr_blocks plus the CIDR of the network named by source_vpc, if any.
+func RuleCIDRBlocks(service *parser.Service, rule parser.SecurityGroupRule) []string {
+	cidrs := append([]string{}, rule.CIDRBlocks...)
+	if rule.SourceVPC != "" {
+		if network := FindNetwork(service, rule.SourceVPC); network != nil {
+			cidrs = append(cidrs, network.CIDR)
+		}
+	}
+	return cidrs
+}
+
+// MergeTags returns the service-wide tags overlaid with resource-specific tags.
+func MergeTags(globalTags, resourceTags map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range globalTags {
+		merged[k] = v
+	}
+	for k, v := range resourceTags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// ClusterSubnets returns the subnets a Kubernetes cluster runs in: the subnets listed in its spec,
+// or every subnet of the cluster VPC when the list is omitted.
+func ClusterSubnets(service *parser.Service, cluster parser.KubernetesCluster) []string {
+	if cluster.TypedSpec != nil && len(cluster.TypedSpec.SubnetNames()) > 0 {
+		return cluster.TypedSpec.SubnetNames()
+	}
+
+	var subnets []string
+	if network := FindNetwork(service, cluster.VPC); network != nil {
+		for _, subnet := range network.Subnets {
+			subnets = append(subnets, subnet.Name)
+		}
+	}
+	return subnets
+}
@@ -0,0 +1,16 @@
+package provider
+
+import "bold/pkg/parser"
+
+// InstanceSize returns the instance type of a compute: the cloud-specific instance type key
+// if set, then the type selected by the provider-agnostic sizing keys, then defaultType.
+func InstanceSize(providerType, instanceType string, sizing parser.ComputeSizing, defaultType string) string {
+	if instanceType != "" {
+		return instanceType
+	}
+	// Sizing errors are reported by validation; an unresolvable sizing falls back to the default.
+	if resolved, err := sizing.Resolve(providerType); err == nil && resolved != "" {
+		return resolved
+	}
+	return defaultType
+}
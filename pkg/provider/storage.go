@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VolumeMount describes where an attached data disk should be mounted inside the guest.
+// Devices lists the candidate block device paths, in order of preference, because the
+// device name a disk appears under differs between clouds and instance generations.
+type VolumeMount struct {
+	Path    string
+	Devices []string
+}
+
+// StorageCloudInit renders a cloud-config document that formats each volume on first boot
+// (only when it has no filesystem yet) and mounts it persistently at its path.
+func StorageCloudInit(mounts []VolumeMount) string {
+	var script strings.Builder
+
+	script.WriteString("#cloud-config\n")
+	script.WriteString("write_files:\n")
+	script.WriteString("  - path: /usr/local/sbin/bolt-mount-volumes.sh\n")
+	script.WriteString("    permissions: \"0755\"\n")
+	script.WriteString("    content: |\n")
+
+	for _, line := range mountScriptLines(mounts) {
+		script.WriteString("      " + line + "\n")
+	}
+
+	script.WriteString("runcmd:\n")
+	script.WriteString("  - /usr/local/sbin/bolt-mount-volumes.sh\n")
+
+	return script.String()
+}
+
+// mountScriptLines returns the shell script that waits for each volume's device, formats
+// it if it has no filesystem yet and mounts it through /etc/fstab.
+func mountScriptLines(mounts []VolumeMount) []string {
+	lines := []string{
+		"#!/bin/sh",
+		"set -e",
+		"mount_volume() {",
+		"  mount_path=\"$1\"",
+		"  shift",
+		"  device=\"\"",
+		"  for attempt in $(seq 1 60); do",
+		"    for candidate in \"$@\"; do",
+		"      if [ -e \"$candidate\" ]; then device=$(readlink -f \"$candidate\"); break 2; fi",
+		"    done",
+		"    sleep 2",
+		"  done",
+		"  if [ -z \"$device\" ]; then echo \"bolt: no device found for $mount_path\" >&2; return 1; fi",
+		"  if ! blkid \"$device\" >/dev/null 2>&1; then mkfs.ext4 -q \"$device\"; fi",
+		"  mkdir -p \"$mount_path\"",
+		"  uuid=$(blkid -s UUID -o value \"$device\")",
+		"  grep -q \"UUID=$uuid\" /etc/fstab || echo \"UUID=$uuid $mount_path ext4 defaults,nofail 0 2\" >> /etc/fstab",
+		"  mountpoint -q \"$mount_path\" || mount \"$mount_path\"",
+		"}",
+	}
+	for _, mount := range mounts {
+		lines = append(lines, fmt.Sprintf("mount_volume %s %s", shellQuote(mount.Path), strings.Join(quoteAll(mount.Devices), " ")))
+	}
+	return lines
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = shellQuote(value)
+	}
+	return quoted
+}
@@ -0,0 +1,76 @@
+package provider
+
+import (
+	"strings"
+
+	"bold/pkg/parser"
+)
+
+// userDataBoundary separates the parts of multi-part user data. It is fixed so that the
+// generated configuration does not change between compilations.
+const userDataBoundary = "==BOLT_USER_DATA=="
+
+// cloudConfigMergeType makes cloud-init append the lists of later cloud-config parts (such
+// as runcmd and packages) to those of earlier parts instead of replacing them.
+const cloudConfigMergeType = "list(append)+dict(recurse_array)+str()"
+
+// UserData renders the cloud-init user data of a compute, or "" when it has neither
+// user_data nor volumes to mount. Volumes are mounted by the first part, so that the
+// user's scripts and runcmd find them in place.
+func UserData(service *parser.Service, compute parser.Compute, mounts []VolumeMount) string {
+	// Invalid user data is rejected by parser.DecodeSpecs.
+	parts, _ := compute.UserData.Parts(parser.UserDataVarsFor(service, compute))
+	for i := range parts {
+		parts[i].Content = escapeInterpolation(parts[i].Content)
+	}
+
+	if len(parts) == 0 {
+		if len(mounts) == 0 {
+			return ""
+		}
+		return StorageCloudInit(mounts)
+	}
+	if len(mounts) == 0 && len(parts) == 1 {
+		return parts[0].Content
+	}
+
+	if len(mounts) > 0 {
+		mountScript := parser.UserDataPart{
+			ContentType: parser.ShellScriptType,
+			Content:     strings.Join(mountScriptLines(mounts), "\n") + "\n",
+		}
+		parts = append([]parser.UserDataPart{mountScript}, parts...)
+	}
+	return multipart(parts)
+}
+
+// multipart joins user data parts into a MIME multi-part document, which cloud-init runs
+// part by part.
+func multipart(parts []parser.UserDataPart) string {
+	var document strings.Builder
+
+	document.WriteString("Content-Type: multipart/mixed; boundary=\"" + userDataBoundary + "\"\n")
+	document.WriteString("MIME-Version: 1.0\n")
+	for _, part := range parts {
+		document.WriteString("\n--" + userDataBoundary + "\n")
+		document.WriteString("Content-Type: " + part.ContentType + "; charset=\"utf-8\"\n")
+		if part.ContentType == parser.CloudConfigType {
+			document.WriteString("Merge-Type: " + cloudConfigMergeType + "\n")
+		}
+		document.WriteString("\n")
+		document.WriteString(part.Content)
+		if !strings.HasSuffix(part.Content, "\n") {
+			document.WriteString("\n")
+		}
+	}
+	document.WriteString("\n--" + userDataBoundary + "--\n")
+
+	return document.String()
+}
+
+// escapeInterpolation escapes the OpenTofu template sequences in user-supplied text, so
+// that shell variables such as ${HOME} reach the instance unchanged.
+func escapeInterpolation(text string) string {
+	text = strings.ReplaceAll(text, "${", "$${")
+	return strings.ReplaceAll(text, "%{", "%%{")
+}
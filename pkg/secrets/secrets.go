@@ -0,0 +1,162 @@
+// Package secrets resolves the secret references manifests use instead of literal secrets,
+// such as env://AZ_VM_PASS. Each URL scheme has a Resolver; env://, file:// and vault:// are
+// built in, and Register lets another implementation take over a scheme.
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resolver returns the value of the secrets of one scheme. path is the reference without
+// the scheme, e.g. AZ_VM_PASS for env://AZ_VM_PASS.
+type Resolver interface {
+	Resolve(path string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(path string) (string, error)
+
+func (f ResolverFunc) Resolve(path string) (string, error) {
+	return f(path)
+}
+
+var (
+	mu        sync.RWMutex
+	resolvers = map[string]Resolver{
+		"env":   ResolverFunc(resolveEnv),
+		"file":  ResolverFunc(resolveFile),
+		"vault": &FileVault{},
+	}
+)
+
+// Register sets the resolver of a scheme, replacing the built-in one if there is any.
+func Register(scheme string, resolver Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolvers[scheme] = resolver
+}
+
+// Schemes returns the schemes that have a resolver, in sorted order.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	schemes := make([]string, 0, len(resolvers))
+	for scheme := range resolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Validate checks that a reference has the form scheme://path with a registered scheme.
+func Validate(ref string) error {
+	_, _, err := split(ref)
+	return err
+}
+
+// Resolve returns the value of a secret reference.
+func Resolve(ref string) (string, error) {
+	resolver, path, err := split(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := resolver.Resolve(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", ref, err)
+	}
+	return value, nil
+}
+
+func split(ref string) (Resolver, string, error) {
+	scheme, path, ok := strings.Cut(ref, "://")
+	if !ok || path == "" {
+		return nil, "", fmt.Errorf("secret reference %q must have the form scheme://path (schemes: %s)", ref, strings.Join(Schemes(), ", "))
+	}
+
+	mu.RLock()
+	resolver, ok := resolvers[scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown secret scheme %s in %q (supported: %s)", scheme, ref, strings.Join(Schemes(), ", "))
+	}
+	return resolver, path, nil
+}
+
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// resolveFile reads a secret from a file, dropping the trailing newline editors add.
+func resolveFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// FileVault is a local stand-in for a Vault server. It reads secrets from a YAML file that
+// maps secret paths to values, or to maps of keys to values:
+//
+//	secret/azure/vm:
+//	  password: s3cret
+//
+// vault://secret/azure/vm#password then resolves to "s3cret".
+type FileVault struct {
+	// Path is the YAML file; when empty, BOLT_VAULT_FILE or $XDG_CONFIG_HOME/bolt/vault.yaml is used.
+	Path string
+}
+
+func (v *FileVault) Resolve(ref string) (string, error) {
+	file := v.Path
+	if file == "" {
+		file = os.Getenv("BOLT_VAULT_FILE")
+	}
+	if file == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot locate the vault file: %w", err)
+		}
+		file = filepath.Join(configDir, "bolt", "vault.yaml")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault file: %w", err)
+	}
+	var store map[string]interface{}
+	if err := yaml.Unmarshal(data, &store); err != nil {
+		return "", fmt.Errorf("failed to parse vault file %s: %w", file, err)
+	}
+
+	path, key, hasKey := strings.Cut(ref, "#")
+	entry, ok := store[path]
+	if !ok {
+		return "", fmt.Errorf("no secret %s in %s", path, file)
+	}
+	keys, isMap := entry.(map[string]interface{})
+	if !hasKey {
+		if isMap {
+			return "", fmt.Errorf("secret %s in %s holds several keys; select one with #key", path, file)
+		}
+		return fmt.Sprint(entry), nil
+	}
+
+	value, ok := keys[key]
+	if !ok {
+		return "", fmt.Errorf("no key %s in secret %s in %s", key, path, file)
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	vaultFile := filepath.Join(dir, "vault.yaml")
+	if err := os.WriteFile(vaultFile, []byte("secret/azure/vm:\n  password: from-vault\nsecret/token: abc\n"), 0600); err != nil {
+		t.Fatalf("Failed to write vault file: %v", err)
+	}
+	t.Setenv("BOLT_TEST_SECRET", "from-env")
+	t.Setenv("BOLT_VAULT_FILE", vaultFile)
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"env://BOLT_TEST_SECRET", "from-env"},
+		{"file://" + passwordFile, "from-file"},
+		{"vault://secret/azure/vm#password", "from-vault"},
+		{"vault://secret/token", "abc"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(tt.ref)
+		if err != nil {
+			t.Errorf("Resolve(%q) error = %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+
+	for _, ref := range []string{
+		"env://BOLT_TEST_UNSET",
+		"vault://secret/azure/vm",
+		"vault://secret/azure/vm#user",
+		"s3cret",
+		"ssm://param",
+	} {
+		if _, err := Resolve(ref); err == nil {
+			t.Errorf("Resolve(%q) succeeded, want an error", ref)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test", ResolverFunc(func(path string) (string, error) {
+		return "resolved-" + path, nil
+	}))
+	defer func() {
+		mu.Lock()
+		delete(resolvers, "test")
+		mu.Unlock()
+	}()
+
+	got, err := Resolve("test://name")
+	if err != nil || got != "resolved-name" {
+		t.Errorf("Resolve() = %q, %v, want the registered resolver to be used", got, err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package sshkey generates ed25519 SSH key pairs in the OpenSSH formats, so that Bolt can
+// create the key pair of a service without depending on ssh-keygen.
+package sshkey
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	keyType      = "ssh-ed25519"
+	privateMagic = "openssh-key-v1\x00"
+	pemType      = "OPENSSH PRIVATE KEY"
+	blockSize    = 8
+)
+
+// Generate creates an ed25519 key pair. It returns the private key as an unencrypted
+// OpenSSH private key file and the public key in authorized_keys format.
+func Generate(comment string) (privateKey []byte, publicKey string, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, "", fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+
+	var section bytes.Buffer
+	section.Write(check[:])
+	section.Write(check[:])
+	writeString(&section, []byte(keyType))
+	writeString(&section, public)
+	writeString(&section, private)
+	writeString(&section, []byte(comment))
+	for i := byte(1); section.Len()%blockSize != 0; i++ {
+		section.WriteByte(i)
+	}
+
+	var key bytes.Buffer
+	key.WriteString(privateMagic)
+	writeString(&key, []byte("none"))
+	writeString(&key, []byte("none"))
+	writeString(&key, nil)
+	binary.Write(&key, binary.BigEndian, uint32(1))
+	writeString(&key, publicKeyBlob(public))
+	writeString(&key, section.Bytes())
+
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: key.Bytes()})
+	return privateKey, authorizedKey(publicKeyBlob(public), comment), nil
+}
+
+// PublicKey returns the public key of an OpenSSH private key file in authorized_keys
+// format. The public key is stored unencrypted, so passphrase-protected keys work too.
+func PublicKey(privateKey []byte, comment string) (string, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil || block.Type != pemType {
+		return "", errors.New("not an OpenSSH private key")
+	}
+
+	data := block.Bytes
+	if !bytes.HasPrefix(data, []byte(privateMagic)) {
+		return "", errors.New("not an OpenSSH private key")
+	}
+	data = data[len(privateMagic):]
+
+	// Skip the cipher name, KDF name and KDF options.
+	for i := 0; i < 3; i++ {
+		var err error
+		if _, data, err = readString(data); err != nil {
+			return "", err
+		}
+	}
+	if len(data) < 4 {
+		return "", errors.New("truncated OpenSSH private key")
+	}
+	if count := binary.BigEndian.Uint32(data); count != 1 {
+		return "", fmt.Errorf("OpenSSH private key holds %d keys, want 1", count)
+	}
+
+	blob, _, err := readString(data[4:])
+	if err != nil {
+		return "", err
+	}
+	return authorizedKey(blob, comment), nil
+}
+
+func publicKeyBlob(public ed25519.PublicKey) []byte {
+	var blob bytes.Buffer
+	writeString(&blob, []byte(keyType))
+	writeString(&blob, public)
+	return blob.Bytes()
+}
+
+// authorizedKey formats a public key blob as an authorized_keys line.
+func authorizedKey(blob []byte, comment string) string {
+	name, _, _ := readString(blob)
+	line := string(name) + " " + base64.StdEncoding.EncodeToString(blob)
+	if comment = strings.TrimSpace(comment); comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// writeString writes an SSH wire format string: a big-endian length followed by the bytes.
+func writeString(buffer *bytes.Buffer, value []byte) {
+	binary.Write(buffer, binary.BigEndian, uint32(len(value)))
+	buffer.Write(value)
+}
+
+// readString reads an SSH wire format string and returns it with the remaining data.
+func readString(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, errors.New("truncated OpenSSH private key")
+	}
+	length := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < length {
+		return nil, nil, errors.New("truncated OpenSSH private key")
+	}
+	return data[4 : 4+length], data[4+length:], nil
+}
@@ -0,0 +1,114 @@
+package workflow
+
+import (
+	"bold/pkg/config"
+	"bold/pkg/errors"
+	"bold/pkg/parser"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// defaultBuildRoot adalah direktori induk untuk build dan state setiap layanan.
+const defaultBuildRoot = "./bolt_build"
+
+// ownerFile mencatat layanan dan environment pemilik sebuah direktori build.
+const ownerFile = ".bolt-owner.json"
+
+// buildOwner adalah isi ownerFile.
+type buildOwner struct {
+	Service     string `json:"service"`
+	Environment string `json:"environment"`
+	Manifest    string `json:"manifest"`
+}
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// serviceEnvironment menentukan environment layanan: --env jika diisi, lalu tag metadata
+// "environment", lalu spec.environment provider pertama yang mengisinya, lalu default dari
+// konfigurasi. --env selalu menang agar setiap overlay punya direktori build dan state sendiri.
+func serviceEnvironment(manifest *parser.Service, cfg *config.Config, env string) string {
+	if env != "" {
+		return env
+	}
+	if env := manifest.Metadata.Tags["environment"]; env != "" {
+		return env
+	}
+	for _, p := range manifest.Providers {
+		if p.TypedSpec != nil && p.TypedSpec.Settings().Environment != "" {
+			return p.TypedSpec.Settings().Environment
+		}
+	}
+	return cfg.Defaults.Environment
+}
+
+// resolveBuildDir mengembalikan direktori build: nilai --build-dir jika diisi,
+// selain itu ./bolt_build/<service>/<environment>.
+func resolveBuildDir(buildDir string, owner buildOwner) string {
+	if buildDir != "" {
+		return buildDir
+	}
+	return filepath.Join(defaultBuildRoot, owner.Service, unsafePathChars.ReplaceAllString(owner.Environment, "-"))
+}
+
+// checkBuildDirOwner menolak direktori build yang state-nya milik layanan atau environment lain,
+// sehingga destroy pada satu manifest tidak pernah menyentuh infrastruktur manifest lain.
+func checkBuildDirOwner(dir string, owner buildOwner) error {
+	data, err := os.ReadFile(filepath.Join(dir, ownerFile))
+	if err == nil {
+		var existing buildOwner
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Join(dir, ownerFile), err)
+		}
+		if existing.Service != owner.Service || existing.Environment != owner.Environment {
+			return &errors.ConfigurationError{
+				Field: "build_dir",
+				Value: dir,
+				Message: fmt.Sprintf("directory belongs to service %s (environment %s), not %s (environment %s); use a different --build-dir",
+					existing.Service, existing.Environment, owner.Service, owner.Environment),
+			}
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", filepath.Join(dir, ownerFile), err)
+	}
+
+	// Tanpa ownerFile, state yang sudah berisi resource tidak bisa dipastikan pemiliknya.
+	if hasManagedResources(filepath.Join(dir, "terraform.tfstate")) {
+		return &errors.ConfigurationError{
+			Field:   "build_dir",
+			Value:   dir,
+			Message: fmt.Sprintf("directory contains OpenTofu state that was not created by bolt for service %s; move the state or use a different --build-dir", owner.Service),
+		}
+	}
+	return nil
+}
+
+// writeBuildDirOwner menandai direktori build sebagai milik layanan dan environment ini.
+func writeBuildDirOwner(dir string, owner buildOwner) error {
+	data, err := json.MarshalIndent(owner, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal build owner: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, ownerFile), data, 0644)
+}
+
+// hasManagedResources melaporkan apakah file state ada dan berisi minimal satu resource.
+func hasManagedResources(statePath string) bool {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return false
+	}
+
+	var state struct {
+		Resources []json.RawMessage `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		// State yang tidak bisa dibaca diperlakukan sebagai berisi resource agar tetap aman.
+		return true
+	}
+	return len(state.Resources) > 0
+}
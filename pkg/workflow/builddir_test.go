@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"bold/pkg/config"
+	"bold/pkg/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckBuildDirOwner(t *testing.T) {
+	dir := t.TempDir()
+	owner := buildOwner{Service: "svc-a", Environment: "local"}
+
+	if err := checkBuildDirOwner(dir, owner); err != nil {
+		t.Fatalf("empty directory rejected: %v", err)
+	}
+
+	if err := writeBuildDirOwner(dir, owner); err != nil {
+		t.Fatalf("writeBuildDirOwner() error = %v", err)
+	}
+	if err := checkBuildDirOwner(dir, owner); err != nil {
+		t.Errorf("own directory rejected: %v", err)
+	}
+	if err := checkBuildDirOwner(dir, buildOwner{Service: "svc-b", Environment: "local"}); err == nil {
+		t.Error("directory of another service accepted")
+	}
+	if err := checkBuildDirOwner(dir, buildOwner{Service: "svc-a", Environment: "production"}); err == nil {
+		t.Error("directory of another environment accepted")
+	}
+
+	foreign := t.TempDir()
+	state := `{"version": 4, "resources": [{"type": "aws_vpc", "name": "main"}]}`
+	if err := os.WriteFile(filepath.Join(foreign, "terraform.tfstate"), []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkBuildDirOwner(foreign, owner); err == nil {
+		t.Error("unowned state with resources accepted")
+	}
+}
+
+func TestResolveBuildDir(t *testing.T) {
+	owner := buildOwner{Service: "svc-a", Environment: "prod/eu"}
+
+	if got, want := resolveBuildDir("", owner), filepath.Join("bolt_build", "svc-a", "prod-eu"); got != want {
+		t.Errorf("resolveBuildDir() = %s, want %s", got, want)
+	}
+	if got := resolveBuildDir("/tmp/custom", owner); got != "/tmp/custom" {
+		t.Errorf("resolveBuildDir() = %s, want /tmp/custom", got)
+	}
+}
+
+func TestOverlayEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	manifestFile := filepath.Join(dir, "service.yaml")
+	manifest := `
+apiVersion: bolt/v1
+kind: Service
+metadata:
+  name: svc-a
+  owner: team
+  tags:
+    environment: local
+spec:
+  state:
+    backend: local
+`
+	if err := os.WriteFile(manifestFile, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "overlays"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"prod", "staging"} {
+		if err := os.WriteFile(parser.OverlayPath(manifestFile, env), []byte("metadata:\n  owner: team\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	buildDirs := map[string]bool{}
+	stateKeys := map[string]bool{}
+	for _, env := range []string{"", "prod", "staging"} {
+		service, err := parser.ParseManifestWithOptions(manifestFile, parser.ParseOptions{Env: env})
+		if err != nil {
+			t.Fatalf("ParseManifestWithOptions(%q) error = %v", env, err)
+		}
+		owner := buildOwner{Service: service.Metadata.Name, Environment: serviceEnvironment(service, cfg, env)}
+		if err := resolveStateBackend(service, cfg, owner); err != nil {
+			t.Fatalf("resolveStateBackend(%q) error = %v", env, err)
+		}
+
+		buildDirs[resolveBuildDir("", owner)] = true
+		stateKeys[service.Spec.State.Key] = true
+		if env != "" && service.Spec.State.Key != "svc-a/"+env {
+			t.Errorf("state key with --env %s = %s, want svc-a/%s", env, service.Spec.State.Key, env)
+		}
+	}
+
+	if len(buildDirs) != 3 {
+		t.Errorf("build directories = %v, want one per environment", buildDirs)
+	}
+	if len(stateKeys) != 3 {
+		t.Errorf("state keys = %v, want one per environment", stateKeys)
+	}
+}
@@ -0,0 +1,41 @@
+package workflow
+
+import (
+	"bold/pkg/config"
+	"bold/pkg/parser"
+	"fmt"
+	"path"
+)
+
+// resolveStateBackend melengkapi spec.state manifest sebelum kompilasi: memakai backend
+// dari konfigurasi jika manifest tidak menentukannya dan mengisi key default
+// <service>/<environment>.
+func resolveStateBackend(manifest *parser.Service, cfg *config.Config, owner buildOwner) error {
+	state := manifest.Spec.State
+	if state.Backend == "" {
+		if cfg.State.Backend == "" {
+			return nil
+		}
+
+		result := &parser.ValidationResult{}
+		parser.ValidateStateBackend(cfg.State, "state", result)
+		if result.HasErrors() {
+			return fmt.Errorf("invalid state backend in configuration: %w", result)
+		}
+		state = cfg.State
+	}
+
+	// Salin config agar konfigurasi global tidak ikut berubah.
+	backendConfig := make(map[string]interface{}, len(state.Config))
+	for name, value := range state.Config {
+		backendConfig[name] = value
+	}
+
+	if state.Key == "" {
+		state.Key = path.Join(owner.Service, unsafePathChars.ReplaceAllString(owner.Environment, "-"))
+	}
+
+	state.Config = backendConfig
+	manifest.Spec.State = state
+	return nil
+}
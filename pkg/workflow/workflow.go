@@ -7,33 +7,75 @@ import (
 	"bold/pkg/errors"
 	"bold/pkg/logger"
 	"bold/pkg/parser"
+	"bold/pkg/plan"
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// Options berisi opsi dari command line untuk Run.
+type Options struct {
+	// BuildDir menggantikan direktori build default ./bolt_build/<service>/<environment>.
+	BuildDir string
+	// ConfigPath menunjuk file konfigurasi; kosong berarti dicari otomatis.
+	ConfigPath string
+	// Profile memilih profil di file konfigurasi; kosong berarti memakai BOLT_PROFILE.
+	Profile string
+	// AutoApprove melewati konfirmasi apply/destroy, mengalahkan security.require_confirmation.
+	AutoApprove bool
+	// Env memilih overlay overlays/<env>.yaml di samping manifest; kosong berarti manifest dasar saja.
+	Env string
+	// Vars berisi nilai variabel manifest dari --var dalam bentuk name=value.
+	Vars []string
+	// VarFiles berisi file YAML nilai variabel manifest dari --var-file.
+	VarFiles []string
+}
+
 // Run menjalankan alur kerja standar: Parse -> Compile -> Execute.
-func Run(manifestFile string, action string) error {
-	cfg, err := config.LoadConfig("")
+func Run(manifestFile string, action string, opts Options) error {
+	cfg, err := config.LoadConfig(opts.ConfigPath, opts.Profile)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	// Flag command line memiliki prioritas tertinggi: flag > env > profil > file > default.
+	if opts.AutoApprove {
+		cfg.Security.RequireConfirmation = false
+	}
+
 	if err := logger.Init(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output); err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
+	if err := logger.SetRedactKeys(cfg.Logging.RedactKeys); err != nil {
+		return err
+	}
 
 	defer logger.LogOperation("workflow", logger.Fields{
 		"manifest_file": manifestFile,
 		"action":        action,
 	})()
 
+	logger.Info("Configuration loaded", logger.Fields{
+		"config_file": cfg.Path,
+		"profile":     cfg.Profile,
+	})
+
 	logger.Info("Starting manifest parsing", logger.Fields{
 		"manifest_file": manifestFile,
 	})
 
-	manifest, err := parser.ParseManifest(manifestFile)
+	manifest, err := parser.ParseManifestWithOptions(manifestFile, parser.ParseOptions{
+		Env:        opts.Env,
+		Vars:       opts.Vars,
+		VarFiles:   opts.VarFiles,
+		LibraryDir: cfg.Components.Library,
+	})
 	if err != nil {
 		logger.LogError(err, "manifest parsing", logger.Fields{
 			"manifest_file": manifestFile,
@@ -50,12 +92,43 @@ func Run(manifestFile string, action string) error {
 		"providers":    len(manifest.Providers),
 	})
 
-	compileDir := "./bolt_build"
+	owner := buildOwner{
+		Service:     manifest.Metadata.Name,
+		Environment: serviceEnvironment(manifest, cfg, opts.Env),
+		Manifest:    manifestFile,
+	}
+	if abs, err := filepath.Abs(manifestFile); err == nil {
+		owner.Manifest = abs
+	}
+
+	if err := resolveStateBackend(manifest, cfg, owner); err != nil {
+		logger.LogError(err, "state backend resolution", logger.Fields{
+			"service": owner.Service,
+		})
+		return err
+	}
+
+	compileDir := resolveBuildDir(opts.BuildDir, owner)
+	if err := checkBuildDirOwner(compileDir, owner); err != nil {
+		logger.LogError(err, "build directory ownership check", logger.Fields{
+			"compile_dir": compileDir,
+		})
+		return err
+	}
+	if opts.BuildDir == "" && hasManagedResources(filepath.Join(defaultBuildRoot, "terraform.tfstate")) {
+		logger.Warn("Found state from an older bolt version in the shared build directory; move it into the per-service directory to keep managing it", logger.Fields{
+			"legacy_dir":  defaultBuildRoot,
+			"compile_dir": compileDir,
+		})
+	}
+
 	logger.Info("Starting compilation", logger.Fields{
 		"compile_dir": compileDir,
+		"service":     owner.Service,
+		"environment": owner.Environment,
 	})
 
-	err = compiler.CompileToTofu(manifest, compileDir)
+	compiled, err := compiler.CompileToTofu(manifest, cfg, compileDir)
 	if err != nil {
 		logger.LogError(err, "compilation", logger.Fields{
 			"compile_dir": compileDir,
@@ -66,6 +139,19 @@ func Run(manifestFile string, action string) error {
 		}
 	}
 
+	// Nilai secret yang sudah di-resolve tidak boleh muncul di log maupun output tofu.
+	for _, env := range compiled.Env {
+		_, value, _ := strings.Cut(env, "=")
+		logger.AddSecretValue(value)
+	}
+
+	if err := writeBuildDirOwner(compileDir, owner); err != nil {
+		logger.LogError(err, "writing build directory owner", logger.Fields{
+			"compile_dir": compileDir,
+		})
+		return err
+	}
+
 	logger.Info("Compilation completed successfully", logger.Fields{
 		"compile_dir": compileDir,
 	})
@@ -74,9 +160,20 @@ func Run(manifestFile string, action string) error {
 		"action": action,
 	})
 
-	tofuEngine := &engine.OpenTofuEngine{WorkDir: compileDir}
+	// Ctrl-C atau SIGTERM membatalkan context sehingga proses tofu ikut dihentikan.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := tofuEngine.Init(); err != nil {
+	tofuEngine := &engine.OpenTofuEngine{
+		WorkDir:      compileDir,
+		Timeout:      time.Duration(cfg.Security.TimeoutSeconds) * time.Second,
+		ApplyTimeout: time.Duration(cfg.Security.ApplyTimeoutSeconds) * time.Second,
+		MaxRetries:   cfg.Security.MaxRetries,
+		NoLock:       !manifest.Spec.State.LockEnabled(),
+		Env:          compiled.Env,
+	}
+
+	if err := tofuEngine.Init(ctx); err != nil {
 		logger.LogError(err, "OpenTofu initialization", logger.Fields{
 			"work_dir": compileDir,
 		})
@@ -88,7 +185,7 @@ func Run(manifestFile string, action string) error {
 	}
 
 	if action == "plan" {
-		if err := tofuEngine.Plan(); err != nil {
+		if err := tofuEngine.Plan(ctx); err != nil {
 			logger.LogError(err, "OpenTofu plan", logger.Fields{
 				"work_dir": compileDir,
 			})
@@ -98,8 +195,22 @@ func Run(manifestFile string, action string) error {
 				ExitCode: 1,
 			}
 		}
+
+		summary, err := summarizePlan(ctx, tofuEngine, manifest)
+		if err != nil {
+			logger.LogError(err, "plan summary", logger.Fields{
+				"work_dir": compileDir,
+			})
+			return &errors.ExecutionError{
+				Command:  "tofu show -json tfplan",
+				Output:   err.Error(),
+				ExitCode: 1,
+			}
+		}
+		fmt.Println()
+		fmt.Print(plan.FormatSummary(summary))
 	} else if action == "apply" {
-		if err := tofuEngine.Plan(); err != nil {
+		if err := tofuEngine.Plan(ctx); err != nil {
 			logger.LogError(err, "OpenTofu plan before apply", logger.Fields{
 				"work_dir": compileDir,
 			})
@@ -110,14 +221,23 @@ func Run(manifestFile string, action string) error {
 			}
 		}
 
+		if summary, err := summarizePlan(ctx, tofuEngine, manifest); err != nil {
+			logger.Warn("Unable to summarize plan before apply", logger.Fields{
+				"error": err.Error(),
+			})
+		} else {
+			fmt.Println()
+			fmt.Print(plan.FormatSummary(summary))
+		}
+
 		if cfg.Security.RequireConfirmation {
-			if !confirmAction("apply") {
+			if !confirmAction(ctx, "apply") {
 				logger.Info("Apply cancelled by user", logger.Fields{})
 				return nil
 			}
 		}
 
-		if err := tofuEngine.Apply(); err != nil {
+		if err := tofuEngine.Apply(ctx); err != nil {
 			logger.LogError(err, "OpenTofu apply", logger.Fields{
 				"work_dir": compileDir,
 			})
@@ -129,13 +249,13 @@ func Run(manifestFile string, action string) error {
 		}
 	} else if action == "destroy" {
 		if cfg.Security.RequireConfirmation {
-			if !confirmAction("destroy") {
+			if !confirmAction(ctx, "destroy") {
 				logger.Info("Destroy cancelled by user", logger.Fields{})
 				return nil
 			}
 		}
 
-		if err := tofuEngine.PlanDestroy(); err != nil {
+		if err := tofuEngine.PlanDestroy(ctx); err != nil {
 			logger.LogError(err, "OpenTofu plan destroy", logger.Fields{
 				"work_dir": compileDir,
 			})
@@ -146,7 +266,7 @@ func Run(manifestFile string, action string) error {
 			}
 		}
 
-		if err := tofuEngine.Destroy(); err != nil {
+		if err := tofuEngine.Destroy(ctx); err != nil {
 			logger.LogError(err, "OpenTofu destroy", logger.Fields{
 				"work_dir": compileDir,
 			})
@@ -165,10 +285,32 @@ func Run(manifestFile string, action string) error {
 	return nil
 }
 
-func confirmAction(action string) bool {
+// summarizePlan membaca plan yang baru dibuat dan mengelompokkannya per resource manifest.
+func summarizePlan(ctx context.Context, tofuEngine engine.Engine, manifest *parser.Service) (*plan.Summary, error) {
+	planJSON, err := tofuEngine.ShowPlan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Summarize(manifest, planJSON)
+}
+
+// confirmAction meminta konfirmasi pengguna; Ctrl-C selama menunggu jawaban dianggap "no".
+func confirmAction(ctx context.Context, action string) bool {
 	fmt.Printf("\n⚠️  Are you sure you want to %s the infrastructure? (yes/no): ", action)
-	reader := bufio.NewReader(os.Stdin)
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(strings.ToLower(response))
-	return response == "yes" || response == "y"
+
+	answer := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		answer <- response
+	}()
+
+	select {
+	case <-ctx.Done():
+		fmt.Println()
+		return false
+	case response := <-answer:
+		response = strings.TrimSpace(strings.ToLower(response))
+		return response == "yes" || response == "y"
+	}
 }